@@ -0,0 +1,416 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/client"
+	"github.com/intel-retail/core-services/profile-launcher/functions"
+)
+
+// Subcommands of the profile launcher, launching a profile needs no subcommand
+var subcommands = map[string]func(args []string) error{
+	"import":  importCommand,
+	"export":  exportCommand,
+	"devices": devicesCommand,
+	"cameras": camerasCommand,
+	"sweep":   sweepCommand,
+	"replay":  replayCommand,
+	"render":  renderCommand,
+	"secrets": secretsCommand,
+	"down":    downCommand,
+}
+
+// Parse flags that can appear before or after the positional arguments
+func parseFlags(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flagSet.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flagSet.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Import a profile from another format: import compose <docker-compose.yaml>
+func importCommand(args []string) error {
+	if len(args) == 0 || args[0] != "compose" {
+		return fmt.Errorf("usage: profile-launcher import compose <docker-compose.yaml> [--output dir]")
+	}
+
+	var outputDir string
+	flagSet := flag.NewFlagSet("import compose", flag.ContinueOnError)
+	flagSet.StringVar(&outputDir, "output", "./imported-profile", "Directory to write the profile config and env files to")
+	positional, err := parseFlags(flagSet, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: profile-launcher import compose <docker-compose.yaml> [--output dir]")
+	}
+
+	report, err := functions.ImportCompose(positional[0], outputDir)
+	if err != nil {
+		return fmt.Errorf("Failed to import compose file %v", err)
+	}
+	fmt.Println("Profile written to", outputDir)
+	if len(report.Dropped) > 0 {
+		fmt.Println("The following compose keys are not supported and were dropped:")
+		for _, dropped := range report.Dropped {
+			fmt.Println("  " + dropped)
+		}
+	}
+	return nil
+}
+
+// Export a resolved profile: export k8s [launch flags] [--kind Pod|Deployment]
+func exportCommand(args []string) error {
+	if len(args) == 0 || args[0] != "k8s" {
+		return fmt.Errorf("usage: profile-launcher export k8s [--kind Pod|Deployment] [--name name] [--output file] [launch flags]")
+	}
+
+	var flags profileFlags
+	var kind, name, output string
+	flagSet := flag.NewFlagSet("export k8s", flag.ContinueOnError)
+	flags.register(flagSet)
+	flagSet.StringVar(&kind, "kind", functions.KubernetesPod, "Kubernetes object running the containers, Pod or Deployment")
+	flagSet.StringVar(&name, "name", "", "Name of the Kubernetes objects, defaults to the profile directory name")
+	flagSet.StringVar(&output, "output", "-", "File to write the manifests to, - for stdout")
+	if _, err := parseFlags(flagSet, args[1:]); err != nil {
+		return err
+	}
+	// GPU indexes are resolved by the host the manifests run on
+	flags.export = true
+	if name == "" {
+		absConfigDir, err := filepath.Abs(flags.configDir)
+		if err != nil {
+			return err
+		}
+		name = filepath.Base(absConfigDir)
+	}
+
+	containersArray, err := flags.initContainers()
+	if err != nil {
+		return fmt.Errorf("Failed to init containers %v", err)
+	}
+	manifests, err := containersArray.ExportKubernetes(name, kind)
+	if err != nil {
+		return fmt.Errorf("Failed to export Kubernetes manifests %v", err)
+	}
+	if output == "-" {
+		_, err = os.Stdout.Write(manifests)
+		return err
+	}
+	return os.WriteFile(output, manifests, 0644)
+}
+
+// List the GPUs and NPUs found on the host with the target device OpenVINO uses for them
+func devicesCommand(args []string) error {
+	var jsonOutput bool
+	flagSet := flag.NewFlagSet("devices", flag.ContinueOnError)
+	flagSet.BoolVar(&jsonOutput, "json", false, "Print the devices as JSON")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	gpus, err := functions.DiscoverGpus()
+	if err != nil {
+		return err
+	}
+	npus, err := functions.DiscoverNpus()
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Gpus []functions.GpuDevice `json:"gpus"`
+			Npus []functions.NpuDevice `json:"npus"`
+		}{gpus, npus})
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TARGET\tNODE\tRENDER\tPCI\tVENDOR\tDEVICE\tDRIVER")
+	for _, gpu := range gpus {
+		target := "-"
+		if gpu.Index >= 0 {
+			target = "GPU." + strconv.Itoa(gpu.Index)
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", target, gpu.Card, gpu.RenderNode, gpu.PCIAddress, gpu.VendorID, gpu.DeviceID, gpu.Driver)
+	}
+	for _, npu := range npus {
+		fmt.Fprintf(writer, "NPU.%v\t%v\t-\t%v\t%v\t%v\t%v\n", npu.Index, npu.Node, npu.PCIAddress, npu.VendorID, npu.DeviceID, npu.Driver)
+	}
+	return writer.Flush()
+}
+
+// List the V4L2 capture cameras with the auto:N input source picking each of them
+func camerasCommand(args []string) error {
+	var jsonOutput, all bool
+	flagSet := flag.NewFlagSet("cameras", flag.ContinueOnError)
+	flagSet.BoolVar(&jsonOutput, "json", false, "Print the cameras as JSON")
+	flagSet.BoolVar(&all, "all", false, "Include metadata and other non-capture nodes")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	cameras, err := functions.DiscoverCameras()
+	if err != nil {
+		return err
+	}
+	var listed []functions.Camera
+	for _, camera := range cameras {
+		if camera.Capture || all {
+			listed = append(listed, camera)
+		}
+	}
+	if jsonOutput {
+		if listed == nil {
+			listed = []functions.Camera{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listed)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "INPUTSRC\tNODE\tNAME\tBUS\tCAPTURE")
+	captureIndex := 0
+	for _, camera := range listed {
+		inputSrc := "-"
+		if camera.Capture {
+			inputSrc = "auto:" + strconv.Itoa(captureIndex)
+			captureIndex++
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", inputSrc, camera.Node, camera.Name, camera.BusInfo, camera.Capture)
+	}
+	return writer.Flush()
+}
+
+// Launch the containers of a past run again: replay <run.json> [-e KEY=VALUE]
+func replayCommand(args []string) error {
+	var launch launchFlags
+	var envOverrides arrayFlags
+	flagSet := flag.NewFlagSet("replay", flag.ContinueOnError)
+	launch.register(flagSet)
+	flagSet.Var(&envOverrides, "e", "Value of an env masked in the run report")
+	positional, err := parseFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: profile-launcher replay <run.json> [-e KEY=VALUE] [--foreground] [--run_dir dir]")
+	}
+
+	pastReport, err := functions.LoadRunReport(positional[0])
+	if err != nil {
+		return err
+	}
+	containersArray, warnings, err := pastReport.ReplayContainers(envOverrides)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	pullWarnings, err := pastReport.PullReplayImages(ctx, cli, &containersArray)
+	warnings = append(warnings, pullWarnings...)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	if err != nil {
+		return err
+	}
+
+	report := functions.NewRunReport(pastReport.ConfigDir)
+	report.ReplayOf = pastReport.RunID
+	if err := launch.run(&containersArray, &report); err != nil {
+		return fmt.Errorf("Failed to run containers %v", err)
+	}
+	return nil
+}
+
+// Stop and remove the containers of a run: down [run.json] [--run_dir dir].
+// Without a run report the latest run under the run directory is taken down.
+func downCommand(args []string) error {
+	var runDir string
+	flagSet := flag.NewFlagSet("down", flag.ContinueOnError)
+	flagSet.StringVar(&runDir, "run_dir", "runs", "Directory the run reports are written under")
+	positional, err := parseFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("usage: profile-launcher down [run.json] [--run_dir dir]")
+	}
+
+	reportPath := ""
+	if len(positional) == 1 {
+		reportPath = positional[0]
+	} else if reportPath, err = functions.LatestRunReport(runDir); err != nil {
+		return err
+	}
+	report, err := functions.LoadRunReport(reportPath)
+	if err != nil {
+		return err
+	}
+	containersArray := report.LaunchedContainers()
+	containersArray.HookContext.LogDir = filepath.Dir(reportPath)
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	for contIndex, _ := range containersArray.Containers {
+		cont := &containersArray.Containers[contIndex]
+		if cont.ContainerID == "" {
+			continue
+		}
+		if _, err := cli.ContainerInspect(ctx, cont.ContainerID); client.IsErrNotFound(err) {
+			fmt.Printf("Container %v was already removed\n", cont.Name)
+			cont.ContainerID = ""
+		}
+	}
+
+	// The final logs are kept next to the run report
+	shutdownErr := containersArray.Shutdown(ctx, functions.DockerRuntime{Client: cli}, containersArray.HookContext.LogDir)
+	if err := report.InspectContainers(ctx, cli); err != nil {
+		fmt.Println(err)
+	}
+	if report.EndTime == nil {
+		report.Finish()
+	}
+	if err := report.Write(reportPath); err != nil {
+		fmt.Printf("Failed to write run report %v\n", err)
+	} else {
+		fmt.Println("Run report written to", reportPath)
+	}
+	shutdownErr = errors.Join(shutdownErr, containersArray.RunHooks(ctx, functions.DockerRuntime{Client: cli}, functions.HookPostStop, nil))
+	if err := containersArray.DockerRemoveContainers(ctx, cli); err != nil {
+		return errors.Join(shutdownErr, err)
+	}
+	return errors.Join(shutdownErr, containersArray.RemoveSecretFiles())
+}
+
+// Print the profile config with Extends and Include merged: render [--configdir dir]
+func renderCommand(args []string) error {
+	var configDir string
+	flagSet := flag.NewFlagSet("render", flag.ContinueOnError)
+	flagSet.StringVar(&configDir, "configdir", "./test-profile/valid-profile", "Directory with the profile config")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	rendered, err := functions.RenderYamlConfig(configDir)
+	if err != nil {
+		return fmt.Errorf("Failed to render profile config %v", err)
+	}
+	_, err = os.Stdout.Write(rendered)
+	return err
+}
+
+// Manage the local secret store: secrets set <name> | list | rm <name>.
+// set reads the value from stdin so it doesn't end up in the shell history.
+func secretsCommand(args []string) error {
+	usage := fmt.Errorf("usage: profile-launcher secrets set <name> | list | rm <name> [--store file] [--key file]")
+	if len(args) == 0 {
+		return usage
+	}
+	defaultConfig := functions.DefaultSecretStoreConfig()
+	var config functions.SecretStoreConfig
+	flagSet := flag.NewFlagSet("secrets", flag.ContinueOnError)
+	flagSet.StringVar(&config.File, "store", defaultConfig.File, "Secret store file")
+	flagSet.StringVar(&config.KeyFile, "key", defaultConfig.KeyFile, "Key file the secrets are encrypted with, created when missing")
+	positional, err := parseFlags(flagSet, args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "set":
+		if len(positional) != 1 {
+			return usage
+		}
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("Failed to read the secret value %v", err)
+		}
+		store, err := functions.OpenSecretStore(config, true)
+		if err != nil {
+			return err
+		}
+		if err := store.Set(positional[0], strings.TrimSuffix(strings.TrimSuffix(string(value), "\n"), "\r")); err != nil {
+			return err
+		}
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Failed to write secret store %v", err)
+		}
+		fmt.Printf("Secret %v stored in %v\n", positional[0], config.File)
+	case "list":
+		if len(positional) != 0 {
+			return usage
+		}
+		store, err := functions.OpenSecretStore(config, false)
+		if err != nil {
+			return err
+		}
+		for _, name := range store.Names() {
+			fmt.Println(name)
+		}
+	case "rm":
+		if len(positional) != 1 {
+			return usage
+		}
+		store, err := functions.OpenSecretStore(config, false)
+		if err != nil {
+			return err
+		}
+		if err := store.Remove(positional[0]); err != nil {
+			return err
+		}
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Failed to write secret store %v", err)
+		}
+	default:
+		return usage
+	}
+	return nil
+}
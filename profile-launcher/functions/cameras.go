@@ -0,0 +1,137 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// V4L2 video node found in /sys/class/video4linux
+type Camera struct {
+	// Device node, for example /dev/video0
+	Node    string `json:"node"`
+	Name    string `json:"name"`
+	BusInfo string `json:"busInfo"`
+	// Index of the node on its device, UVC cameras expose metadata on index 1
+	Index   int  `json:"index"`
+	Capture bool `json:"capture"`
+}
+
+// Query a V4L2 node such as video0, asking the driver when the node can be
+// opened and falling back on sysfs otherwise
+func QueryCamera(node string) (Camera, error) {
+	sysfsDir := filepath.Join(sysRoot, "class", "video4linux", node)
+	if _, err := os.Stat(sysfsDir); err != nil {
+		return Camera{}, fmt.Errorf("/dev/%v is not a V4L2 device", node)
+	}
+
+	camera := Camera{
+		Node: "/dev/" + node,
+		Name: readSysfsAttribute(filepath.Join(sysfsDir, "name")),
+	}
+	camera.Index, _ = strconv.Atoi(readSysfsAttribute(filepath.Join(sysfsDir, "index")))
+
+	name, busInfo, capture, err := queryV4l2Capability(filepath.Join(devRoot, node))
+	if err == nil {
+		camera.BusInfo = busInfo
+		camera.Capture = capture
+		if camera.Name == "" {
+			camera.Name = name
+		}
+		return camera, nil
+	}
+
+	// Without access to the node, assume the first node of a device captures
+	// and later ones carry metadata like UVC does
+	camera.Capture = camera.Index == 0
+	if devicePath, err := filepath.EvalSymlinks(filepath.Join(sysfsDir, "device")); err == nil {
+		camera.BusInfo = filepath.Base(devicePath)
+	}
+	return camera, nil
+}
+
+// List the V4L2 nodes of /sys/class/video4linux in node number order,
+// metadata and output nodes included
+func DiscoverCameras() ([]Camera, error) {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "class", "video4linux"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to list cameras %v", err)
+	}
+
+	var nodes []int
+	for _, entry := range entries {
+		if !videoNodePattern.MatchString(entry.Name()) {
+			continue
+		}
+		number, _ := strconv.Atoi(strings.TrimPrefix(entry.Name(), "video"))
+		nodes = append(nodes, number)
+	}
+	sort.Ints(nodes)
+
+	var cameras []Camera
+	for _, number := range nodes {
+		camera, err := QueryCamera("video" + strconv.Itoa(number))
+		if err != nil {
+			return nil, err
+		}
+		cameras = append(cameras, camera)
+	}
+	return cameras, nil
+}
+
+// Find the Nth capture camera, counting from 0 and skipping metadata nodes
+func FindCaptureCamera(index int) (Camera, error) {
+	cameras, err := DiscoverCameras()
+	if err != nil {
+		return Camera{}, err
+	}
+	available := 0
+	for _, camera := range cameras {
+		if !camera.Capture {
+			continue
+		}
+		if available == index {
+			return camera, nil
+		}
+		available++
+	}
+	return Camera{}, fmt.Errorf("Camera auto:%d not found, the host has %d capture camera(s), run \"profile-launcher cameras\" to list them", index, available)
+}
+
+// Check a /dev/videoN path is a V4L2 capture device
+func CheckCaptureDevice(path string) error {
+	node := strings.TrimPrefix(path, "/dev/")
+	if _, err := os.Stat(filepath.Join(devRoot, node)); err != nil {
+		return fmt.Errorf("Camera %v does not exist", path)
+	}
+	camera, err := QueryCamera(node)
+	if err != nil {
+		return err
+	}
+	if !camera.Capture {
+		return fmt.Errorf("Camera %v (%v) is not a video capture device, it may be a metadata node", path, camera.Name)
+	}
+	return nil
+}
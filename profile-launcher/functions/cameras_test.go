@@ -0,0 +1,54 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryCamera: test querying V4L2 nodes from a fake sysfs tree
+func TestQueryCamera(t *testing.T) {
+	CreateFakeDevTree(t, "video0", "video1")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeCamera(t, fakeSysRoot, "video0", "0", "USB Camera", "1-1:1.0")
+	AddFakeCamera(t, fakeSysRoot, "video1", "1", "USB Camera", "1-1:1.0")
+
+	tests := []struct {
+		name           string
+		node           string
+		expectedErr    bool
+		expectedCamera Camera
+	}{
+		{"valid capture node", "video0", false, Camera{Node: "/dev/video0", Name: "USB Camera", BusInfo: "1-1:1.0", Index: 0, Capture: true}},
+		{"valid metadata node", "video1", false, Camera{Node: "/dev/video1", Name: "USB Camera", BusInfo: "1-1:1.0", Index: 1, Capture: false}},
+		{"invalid missing node", "video2", true, Camera{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasError := false
+			camera, err := QueryCamera(tt.node)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			require.Equal(t, tt.expectedCamera, camera)
+		})
+	}
+}
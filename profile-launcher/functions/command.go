@@ -0,0 +1,115 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Accept the exec form "Entrypoint: [a, b]" as well as a string split into
+// words like a POSIX shell does
+func (args *CommandArgs) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		words, err := ParseShellWords(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		*args = nil
+		if len(words) > 0 {
+			*args = words
+		}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*args = list
+		return nil
+	}
+	return fmt.Errorf("line %d: command must be a string or a list of strings", node.Line)
+}
+
+// Split a command line into words with the POSIX shell quoting rules: single
+// quotes keep everything, double quotes and backslashes escape. Variables and
+// globs are not expanded.
+func ParseShellWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+	for index := 0; index < len(runes); index++ {
+		char := runes[index]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case char == '\\':
+			index++
+			if index == len(runes) {
+				return nil, fmt.Errorf("command %q ends with a backslash", line)
+			}
+			// A backslash before a newline joins the lines
+			if runes[index] != '\n' {
+				word.WriteRune(runes[index])
+				inWord = true
+			}
+		case char == '\'':
+			end := index + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("command %q has an unterminated single quote", line)
+			}
+			word.WriteString(string(runes[index+1 : end]))
+			inWord = true
+			index = end
+		case char == '"':
+			index++
+			for ; index < len(runes) && runes[index] != '"'; index++ {
+				// In double quotes a backslash only escapes $ ` " \ and newlines
+				if runes[index] == '\\' && index+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[index+1]) {
+					index++
+					if runes[index] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[index])
+			}
+			if index == len(runes) {
+				return nil, fmt.Errorf("command %q has an unterminated double quote", line)
+			}
+			inWord = true
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,117 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestCommandYaml: test the forms of the container Command
+func TestCommandYaml(t *testing.T) {
+	tests := []struct {
+		name            string
+		yaml            string
+		expectedErr     bool
+		expectedCommand CommandArgs
+	}{
+		{"valid list", "Command: [--model, yolov5s]", false, CommandArgs{"--model", "yolov5s"}},
+		{"valid string", "Command: --model  yolov5s", false, CommandArgs{"--model", "yolov5s"}},
+		{"valid quoted string", `Command: --name "my pipeline" 'a b'`, false, CommandArgs{"--name", "my pipeline", "a b"}},
+		{"valid empty string", "Command: ''", false, nil},
+		{"valid not set", "Name: Client", false, nil},
+		{"invalid mapping", "Command:\n  model: yolov5s", true, nil},
+		{"invalid unterminated quote", `Command: --name "my pipeline`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cont := Container{}
+			hasError := false
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cont); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedCommand, cont.Command)
+			}
+		})
+	}
+}
+
+// TestEntrypointYaml: test the forms of the container Entrypoint
+func TestEntrypointYaml(t *testing.T) {
+	tests := []struct {
+		name               string
+		yaml               string
+		expectedErr        bool
+		expectedEntrypoint CommandArgs
+	}{
+		{"valid list", "Entrypoint: [/bin/sh, -c, echo a b]", false, CommandArgs{"/bin/sh", "-c", "echo a b"}},
+		{"valid string", "Entrypoint: /script/entrypoint.sh", false, CommandArgs{"/script/entrypoint.sh"}},
+		{"valid quoted string", `Entrypoint: /bin/sh -c 'echo "a  b"'`, false, CommandArgs{"/bin/sh", "-c", `echo "a  b"`}},
+		{"valid not set", "Name: Client", false, nil},
+		{"invalid unterminated quote", "Entrypoint: /bin/sh -c 'echo", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cont := Container{}
+			hasError := false
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cont); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedEntrypoint, cont.Entrypoint)
+			}
+		})
+	}
+}
+
+// TestParseShellWords: test shell word splitting of command strings
+func TestParseShellWords(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		expectedErr   bool
+		expectedWords []string
+	}{
+		{"valid empty", "", false, nil},
+		{"valid spaces", "  a \t b\n c  ", false, []string{"a", "b", "c"}},
+		{"valid single quotes", `'a "b" \c'`, false, []string{`a "b" \c`}},
+		{"valid double quotes", `"a 'b' \"c\" \$d \e"`, false, []string{`a 'b' "c" $d \e`}},
+		{"valid joined quotes", `--name="my pipeline"x`, false, []string{"--name=my pipelinex"}},
+		{"valid empty quotes", `a "" ''`, false, []string{"a", "", ""}},
+		{"valid backslash", `a\ b \'c`, false, []string{"a b", "'c"}},
+		{"valid line continuation", "a \\\nb", false, []string{"a", "b"}},
+		{"valid no expansion", "echo $HOME *", false, []string{"echo", "$HOME", "*"}},
+		{"invalid single quote", "echo 'a", true, nil},
+		{"invalid double quote", `echo "a`, true, nil},
+		{"invalid trailing backslash", `echo \`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := ParseShellWords(tt.line)
+			require.Equal(t, tt.expectedErr, err != nil)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedWords, words)
+			}
+		})
+	}
+}
@@ -0,0 +1,837 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// Report of the compose keys that could not be mapped to the profile config
+type ComposeImportReport struct {
+	Dropped []string
+}
+
+// Profile config layout written by the compose import, only the fields set
+// by the import are written so the generated file stays readable
+type importedProfile struct {
+	Network    *NetworkConfig      `yaml:"Network,omitempty"`
+	Containers []importedContainer `yaml:"Containers"`
+}
+
+type importedContainer struct {
+	Name                     string                 `yaml:"Name"`
+	DockerImage              string                 `yaml:"DockerImage"`
+	EnvironmentVariableFiles string                 `yaml:"EnvironmentVariableFiles"`
+	InputSrc                 string                 `yaml:"InputSrc"`
+	Entrypoint               []string               `yaml:"Entrypoint,omitempty"`
+	Command                  []string               `yaml:"Command,omitempty"`
+	WorkingDir               string                 `yaml:"WorkingDir,omitempty"`
+	User                     string                 `yaml:"User,omitempty"`
+	Hostname                 string                 `yaml:"Hostname,omitempty"`
+	Labels                   map[string]string      `yaml:"Labels,omitempty"`
+	StopSignal               string                 `yaml:"StopSignal,omitempty"`
+	StopTimeout              *int                   `yaml:"StopTimeout,omitempty"`
+	Tty                      bool                   `yaml:"Tty,omitempty"`
+	Volumes                  []VolumeSpec           `yaml:"Volumes,omitempty"`
+	Ports                    []string               `yaml:"Ports,omitempty"`
+	RestartPolicy            string                 `yaml:"RestartPolicy,omitempty"`
+	Healthcheck              *Healthcheck           `yaml:"Healthcheck,omitempty"`
+	DependsOn                []string               `yaml:"DependsOn,omitempty"`
+	HostConfig               map[string]interface{} `yaml:"HostConfig,omitempty"`
+}
+
+// Compose service after decoding the supported keys
+type composeService struct {
+	service     string
+	name        string
+	image       string
+	entrypoint  []string
+	command     []string
+	workingDir  string
+	user        string
+	hostname    string
+	labels      map[string]string
+	stopSignal  string
+	stopTimeout *int
+	tty         bool
+	envs        []string
+	volumes     []VolumeSpec
+	devices     []container.DeviceMapping
+	ports       []string
+	restart     string
+	healthcheck *Healthcheck
+	networkMode string
+	ipcMode     string
+	privileged  bool
+	dependsOn   []string
+}
+
+// Convert a docker-compose file into a profile_config.yaml and one env file
+// per container written to outputDir
+func ImportCompose(composePath string, outputDir string) (ComposeImportReport, error) {
+	report := ComposeImportReport{}
+	contents, err := os.ReadFile(composePath)
+	if err != nil {
+		return report, fmt.Errorf("Unable to read compose file: %v, error: %v", composePath, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(contents, &root); err != nil {
+		return report, fmt.Errorf("error: %v", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return report, fmt.Errorf("compose file %v is not a yaml mapping", composePath)
+	}
+
+	var services []composeService
+	composeDir := filepath.Dir(composePath)
+	vars, err := loadComposeVariables(composeDir)
+	if err != nil {
+		return report, err
+	}
+	top := root.Content[0]
+	for i := 0; i < len(top.Content); i += 2 {
+		key, value := top.Content[i].Value, top.Content[i+1]
+		switch key {
+		case "version", "name":
+			// Informational only
+		case "services":
+			if value.Kind != yaml.MappingNode {
+				return report, fmt.Errorf("compose services must be a mapping")
+			}
+			for j := 0; j < len(value.Content); j += 2 {
+				vars.interpolateService(value.Content[j].Value, value.Content[j+1], &report)
+			}
+			for j := 0; j < len(value.Content); j += 2 {
+				service, err := decodeComposeService(value.Content[j].Value, value.Content[j+1], composeDir, &report)
+				if err != nil {
+					return report, err
+				}
+				services = append(services, service)
+			}
+		default:
+			report.Dropped = append(report.Dropped, key)
+		}
+	}
+	if len(services) == 0 {
+		return report, fmt.Errorf("compose file %v has no services", composePath)
+	}
+
+	profile, envFiles, err := buildImportedProfile(services)
+	if err != nil {
+		return report, err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return report, fmt.Errorf("Failed to create output directory %v: %v", outputDir, err)
+	}
+	profileConfigPath := filepath.Join(outputDir, "profile_config.yaml")
+	if _, err := os.Stat(profileConfigPath); err == nil {
+		return report, fmt.Errorf("%v already exists, refusing to overwrite", profileConfigPath)
+	}
+	for fileName, envs := range envFiles {
+		if err := os.WriteFile(filepath.Join(outputDir, fileName), []byte(strings.Join(envs, "\n")), 0644); err != nil {
+			return report, fmt.Errorf("Failed to write env file %v: %v", fileName, err)
+		}
+	}
+	profileBytes, err := yaml.Marshal(profile)
+	if err != nil {
+		return report, err
+	}
+	if err := os.WriteFile(profileConfigPath, profileBytes, 0644); err != nil {
+		return report, fmt.Errorf("Failed to write profile config %v: %v", profileConfigPath, err)
+	}
+
+	return report, nil
+}
+
+// Variables compose interpolates, the host env wins over the .env file next
+// to the compose file
+type composeVariables struct {
+	dotEnv map[string]string
+}
+
+// ${VAR} with an optional :-, -, :?, ?, :+ or + modifier, $VAR or $$
+var composeVariablePattern = regexp.MustCompile(`\$(?:\$|\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?+])([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+func loadComposeVariables(composeDir string) (composeVariables, error) {
+	vars := composeVariables{dotEnv: map[string]string{}}
+	contents, err := os.ReadFile(filepath.Join(composeDir, ".env"))
+	if os.IsNotExist(err) {
+		return vars, nil
+	} else if err != nil {
+		return vars, fmt.Errorf("Unable to read env file: %v, error: %v", filepath.Join(composeDir, ".env"), err)
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars.dotEnv[strings.TrimSpace(key)] = value
+	}
+	return vars, nil
+}
+
+func (vars composeVariables) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := vars.dotEnv[name]
+	return value, ok
+}
+
+// Replace the variables of a value, the names of variables that are not set
+// and have no default are returned
+func (vars composeVariables) interpolate(value string) (string, []string) {
+	var missing []string
+	interpolated := composeVariablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		groups := composeVariablePattern.FindStringSubmatch(match)
+		name, modifier, word := groups[1]+groups[4], groups[2], groups[3]
+		varValue, set := vars.lookup(name)
+		switch modifier {
+		case ":-":
+			if !set || varValue == "" {
+				return word
+			}
+		case "-":
+			if !set {
+				return word
+			}
+		case ":+":
+			if set && varValue != "" {
+				return word
+			}
+			return ""
+		case "+":
+			if set {
+				return word
+			}
+			return ""
+		case ":?":
+			set = set && varValue != ""
+		}
+		if !set {
+			missing = append(missing, name)
+		}
+		return varValue
+	})
+	return interpolated, missing
+}
+
+// Interpolate the environment and volumes of a service, entries using a
+// variable that is not set are dropped
+func (vars composeVariables) interpolateService(serviceName string, node *yaml.Node, report *ComposeImportReport) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if key != "environment" && key != "volumes" {
+			continue
+		}
+		drop := func(entry string, missing []string) {
+			report.Dropped = append(report.Dropped, "services."+serviceName+"."+key+"."+entry+" ("+strings.Join(missing, ", ")+" not set)")
+		}
+		var kept []*yaml.Node
+		switch value.Kind {
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				entry := item.Value
+				if item.Kind == yaml.MappingNode {
+					entry = composeMappingValue(item, "target")
+				}
+				if missing := vars.interpolateNode(item); len(missing) > 0 {
+					drop(entry, missing)
+					continue
+				}
+				kept = append(kept, item)
+			}
+		case yaml.MappingNode:
+			for j := 0; j < len(value.Content); j += 2 {
+				if missing := vars.interpolateNode(value.Content[j+1]); len(missing) > 0 {
+					drop(value.Content[j].Value, missing)
+					continue
+				}
+				kept = append(kept, value.Content[j], value.Content[j+1])
+			}
+		default:
+			continue
+		}
+		value.Content = kept
+	}
+}
+
+// Interpolate the scalars of a node and return the variables that are not set
+func (vars composeVariables) interpolateNode(node *yaml.Node) []string {
+	if node.Kind == yaml.ScalarNode {
+		if node.Tag == "!!null" {
+			return nil
+		}
+		var missing []string
+		node.Value, missing = vars.interpolate(node.Value)
+		return missing
+	}
+	var missing []string
+	for index, child := range node.Content {
+		// Keys of mappings are not interpolated
+		if node.Kind == yaml.MappingNode && index%2 == 0 {
+			continue
+		}
+		missing = append(missing, vars.interpolateNode(child)...)
+	}
+	return missing
+}
+
+// Value of a key of a mapping node, empty when it isn't set
+func composeMappingValue(node *yaml.Node, key string) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+func decodeComposeService(serviceName string, node *yaml.Node, composeDir string, report *ComposeImportReport) (composeService, error) {
+	service := composeService{service: serviceName, name: serviceName}
+	if node.Kind != yaml.MappingNode {
+		return service, fmt.Errorf("compose service %v must be a mapping", serviceName)
+	}
+
+	var envFileEnvs, environmentEnvs []string
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "image":
+			err = value.Decode(&service.image)
+		case "container_name":
+			err = value.Decode(&service.name)
+		case "entrypoint":
+			service.entrypoint, err = decodeStringOrList(value)
+		case "command":
+			service.command, err = decodeStringOrList(value)
+		case "working_dir":
+			err = value.Decode(&service.workingDir)
+		case "user":
+			err = value.Decode(&service.user)
+		case "hostname":
+			err = value.Decode(&service.hostname)
+		case "labels":
+			service.labels, err = decodeComposeLabels(value)
+		case "stop_signal":
+			err = value.Decode(&service.stopSignal)
+		case "stop_grace_period":
+			service.stopTimeout, err = decodeComposeDuration(value)
+		case "tty":
+			err = value.Decode(&service.tty)
+		case "environment":
+			environmentEnvs, err = decodeComposeEnvironment(value, serviceName, report)
+		case "env_file":
+			envFileEnvs, err = decodeComposeEnvFiles(value, composeDir)
+		case "volumes":
+			service.volumes, err = decodeComposeVolumes(value, serviceName, composeDir, report)
+		case "devices":
+			service.devices, err = decodeComposeDevices(value)
+		case "restart":
+			if err = value.Decode(&service.restart); err == nil {
+				_, err = ParseRestartPolicy(service.restart)
+			}
+		case "healthcheck":
+			service.healthcheck, err = decodeComposeHealthcheck(value)
+		case "ports":
+			service.ports, err = decodeComposePorts(value)
+		case "network_mode":
+			err = value.Decode(&service.networkMode)
+		case "ipc":
+			err = value.Decode(&service.ipcMode)
+		case "privileged":
+			err = value.Decode(&service.privileged)
+		case "depends_on":
+			service.dependsOn, err = decodeComposeDependsOn(value)
+		default:
+			report.Dropped = append(report.Dropped, "services."+serviceName+"."+key)
+		}
+		if err != nil {
+			return service, fmt.Errorf("compose service %v key %v: %v", serviceName, key, err)
+		}
+	}
+	if service.image == "" {
+		return service, fmt.Errorf("compose service %v has no image, build is not supported", serviceName)
+	}
+
+	// environment takes precedence over env_file like in compose
+	service.envs = mergeEnvs(envFileEnvs, environmentEnvs)
+	return service, nil
+}
+
+// Decode a compose value that can be either a string split like a shell
+// does or a list of strings
+func decodeStringOrList(node *yaml.Node) ([]string, error) {
+	if node.Kind == yaml.ScalarNode {
+		return ParseShellWords(node.Value)
+	}
+	var list []string
+	err := node.Decode(&list)
+	return list, err
+}
+
+// Decode published ports given as short strings or long form mappings
+func decodeComposePorts(node *yaml.Node) ([]string, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("ports must be a list")
+	}
+	var ports []string
+	for _, item := range node.Content {
+		port := item.Value
+		if item.Kind == yaml.MappingNode {
+			var long struct {
+				Target    int    `yaml:"target"`
+				Published string `yaml:"published"`
+				Protocol  string `yaml:"protocol"`
+				HostIP    string `yaml:"host_ip"`
+			}
+			if err := item.Decode(&long); err != nil {
+				return nil, err
+			}
+			if long.Target == 0 {
+				return nil, fmt.Errorf("port %v has no target", item.Line)
+			}
+			port = strconv.Itoa(long.Target)
+			if long.Published != "" {
+				port = long.Published + ":" + port
+			}
+			if long.HostIP != "" {
+				port = long.HostIP + ":" + port
+			}
+			if long.Protocol != "" {
+				port += "/" + long.Protocol
+			}
+		} else if item.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("ports must be strings or mappings")
+		}
+		if _, _, err := nat.ParsePortSpecs([]string{port}); err != nil {
+			return nil, err
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// Decode labels given as a mapping or a list of key=value
+func decodeComposeLabels(node *yaml.Node) (map[string]string, error) {
+	labels := map[string]string{}
+	if node.Kind == yaml.MappingNode {
+		err := node.Decode(&labels)
+		return labels, err
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return nil, err
+	}
+	for _, label := range list {
+		key, value, _ := strings.Cut(label, "=")
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// Decode a compose duration such as 1m30s into whole seconds
+func decodeComposeDuration(node *yaml.Node) (*int, error) {
+	duration, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return nil, err
+	}
+	seconds := int(duration.Round(time.Second).Seconds())
+	return &seconds, nil
+}
+
+// Decode a healthcheck, disable turns off the healthcheck of the image
+func decodeComposeHealthcheck(node *yaml.Node) (*Healthcheck, error) {
+	var compose struct {
+		Test        HealthcheckTest `yaml:"test"`
+		Interval    string          `yaml:"interval"`
+		Timeout     string          `yaml:"timeout"`
+		Retries     int             `yaml:"retries"`
+		StartPeriod string          `yaml:"start_period"`
+		Disable     bool            `yaml:"disable"`
+	}
+	if err := node.Decode(&compose); err != nil {
+		return nil, err
+	}
+	healthcheck := Healthcheck{
+		Test:        compose.Test,
+		Interval:    compose.Interval,
+		Timeout:     compose.Timeout,
+		Retries:     compose.Retries,
+		StartPeriod: compose.StartPeriod,
+	}
+	if compose.Disable {
+		healthcheck = Healthcheck{Test: HealthcheckTest{"NONE"}}
+	}
+	if err := healthcheck.validate(); err != nil {
+		return nil, err
+	}
+	return &healthcheck, nil
+}
+
+func decodeComposeEnvironment(node *yaml.Node, serviceName string, report *ComposeImportReport) ([]string, error) {
+	var envs []string
+	addEnv := func(key string, value *string) {
+		if value != nil {
+			envs = append(envs, key+"="+*value)
+		} else if hostValue, ok := os.LookupEnv(key); ok {
+			// Compose reads values without an assignment from the host
+			envs = append(envs, key+"="+hostValue)
+		} else {
+			report.Dropped = append(report.Dropped, "services."+serviceName+".environment."+key+" (no value and not set on the host)")
+		}
+	}
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return nil, err
+		}
+		for _, env := range list {
+			if key, value, found := strings.Cut(env, "="); found {
+				addEnv(key, &value)
+			} else {
+				addEnv(env, nil)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			valueNode := node.Content[i+1]
+			if valueNode.Tag == "!!null" {
+				addEnv(node.Content[i].Value, nil)
+			} else {
+				addEnv(node.Content[i].Value, &valueNode.Value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("environment must be a list or a mapping")
+	}
+	return envs, nil
+}
+
+func decodeComposeEnvFiles(node *yaml.Node, composeDir string) ([]string, error) {
+	var envFiles []string
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				// Long syntax {path: ..., required: ...}
+				var envFile struct {
+					Path string `yaml:"path"`
+				}
+				if err := item.Decode(&envFile); err != nil {
+					return nil, err
+				}
+				envFiles = append(envFiles, envFile.Path)
+			} else {
+				envFiles = append(envFiles, item.Value)
+			}
+		}
+	} else {
+		envFiles = append(envFiles, node.Value)
+	}
+
+	var envs []string
+	for _, envFile := range envFiles {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(composeDir, envFile)
+		}
+		contents, err := os.ReadFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read env file: %v, error: %v", envFile, err)
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			envs = append(envs, line)
+		}
+	}
+	return envs, nil
+}
+
+// Decode volumes, relative bind sources are made absolute from the compose
+// file directory like compose does so the profile runs from any directory
+func decodeComposeVolumes(node *yaml.Node, serviceName string, composeDir string, report *ComposeImportReport) ([]VolumeSpec, error) {
+	var volumes []VolumeSpec
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			// A target alone is an anonymous volume
+			if !strings.Contains(item.Value, ":") {
+				report.Dropped = append(report.Dropped, "services."+serviceName+".volumes."+item.Value+" (anonymous volume)")
+				continue
+			}
+			spec, err := ParseVolume(item.Value)
+			if err != nil {
+				report.Dropped = append(report.Dropped, "services."+serviceName+".volumes."+item.Value+" ("+err.Error()+")")
+				continue
+			}
+			if spec.Source, err = composeBindSource(spec, composeDir); err != nil {
+				return nil, err
+			}
+			volumes = append(volumes, spec)
+			continue
+		}
+
+		var longVolume struct {
+			Type     string `yaml:"type"`
+			Source   string `yaml:"source"`
+			Target   string `yaml:"target"`
+			ReadOnly bool   `yaml:"read_only"`
+			Bind     struct {
+				Propagation string `yaml:"propagation"`
+				SELinux     string `yaml:"selinux"`
+			} `yaml:"bind"`
+			Volume struct {
+				NoCopy bool `yaml:"nocopy"`
+			} `yaml:"volume"`
+			Tmpfs struct {
+				Size string `yaml:"size"`
+			} `yaml:"tmpfs"`
+		}
+		if err := item.Decode(&longVolume); err != nil {
+			return nil, err
+		}
+		spec := VolumeSpec{
+			Type:     mount.Type(longVolume.Type),
+			Source:   longVolume.Source,
+			Target:   longVolume.Target,
+			ReadOnly: longVolume.ReadOnly,
+		}
+		switch spec.Type {
+		case mount.TypeBind:
+			if longVolume.Bind.Propagation != "" || longVolume.Bind.SELinux != "" {
+				spec.Bind = &BindOptions{Propagation: mount.Propagation(longVolume.Bind.Propagation), SELinux: longVolume.Bind.SELinux}
+			}
+		case mount.TypeVolume:
+			if spec.Source == "" {
+				report.Dropped = append(report.Dropped, "services."+serviceName+".volumes."+spec.Target+" (anonymous volume)")
+				continue
+			}
+			if longVolume.Volume.NoCopy {
+				spec.Volume = &NamedVolumeOptions{NoCopy: true}
+			}
+		case mount.TypeTmpfs:
+			if longVolume.Tmpfs.Size != "" {
+				spec.Tmpfs = &TmpfsOptions{Size: longVolume.Tmpfs.Size}
+			}
+		}
+		if err := spec.validate(); err != nil {
+			report.Dropped = append(report.Dropped, "services."+serviceName+".volumes."+spec.Target+" ("+err.Error()+")")
+			continue
+		}
+		var err error
+		if spec.Source, err = composeBindSource(spec, composeDir); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, spec)
+	}
+	return volumes, nil
+}
+
+// Source of a bind volume with ~ expanded and relative paths taken from the
+// compose file directory, other sources are kept
+func composeBindSource(spec VolumeSpec, composeDir string) (string, error) {
+	if spec.Type != mount.TypeBind {
+		return spec.Source, nil
+	}
+	source := expandHome(spec.Source)
+	if filepath.IsAbs(source) {
+		return source, nil
+	}
+	source, err := filepath.Abs(filepath.Join(composeDir, source))
+	if err != nil {
+		return "", fmt.Errorf("Failed to get volume path %v", err)
+	}
+	return source, nil
+}
+
+func decodeComposeDevices(node *yaml.Node) ([]container.DeviceMapping, error) {
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return nil, err
+	}
+
+	var devices []container.DeviceMapping
+	for _, device := range list {
+		deviceSplit := strings.Split(device, ":")
+		deviceMapping := container.DeviceMapping{
+			PathOnHost:        deviceSplit[0],
+			PathInContainer:   deviceSplit[0],
+			CgroupPermissions: "rwm",
+		}
+		if len(deviceSplit) > 1 {
+			deviceMapping.PathInContainer = deviceSplit[1]
+		}
+		if len(deviceSplit) > 2 {
+			deviceMapping.CgroupPermissions = deviceSplit[2]
+		}
+		devices = append(devices, deviceMapping)
+	}
+	return devices, nil
+}
+
+func decodeComposeDependsOn(node *yaml.Node) ([]string, error) {
+	if node.Kind == yaml.MappingNode {
+		// Long syntax {service: {condition: ...}}
+		var dependsOn []string
+		for i := 0; i < len(node.Content); i += 2 {
+			dependsOn = append(dependsOn, node.Content[i].Value)
+		}
+		return dependsOn, nil
+	}
+	var dependsOn []string
+	err := node.Decode(&dependsOn)
+	return dependsOn, err
+}
+
+// Merge env lists, later lists override earlier ones by key
+func mergeEnvs(envLists ...[]string) []string {
+	var merged []string
+	index := map[string]int{}
+	for _, envs := range envLists {
+		for _, env := range envs {
+			key, _, _ := strings.Cut(env, "=")
+			if i, ok := index[key]; ok {
+				merged[i] = env
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, env)
+		}
+	}
+	return merged
+}
+
+func buildImportedProfile(services []composeService) (importedProfile, map[string][]string, error) {
+	profile := importedProfile{}
+	envFiles := map[string][]string{}
+
+	// depends_on references service names, the profile references container names
+	containerNames := map[string]string{}
+	for _, service := range services {
+		containerNames[service.service] = service.name
+	}
+
+	for _, service := range services {
+		envFileName := service.name + ".env"
+		envFiles[envFileName] = service.envs
+		cont := importedContainer{
+			Name:                     service.name,
+			DockerImage:              service.image,
+			EnvironmentVariableFiles: envFileName,
+			// Compose services take no input source from the launcher
+			InputSrc:      "none",
+			Entrypoint:    service.entrypoint,
+			Command:       service.command,
+			WorkingDir:    service.workingDir,
+			User:          service.user,
+			Hostname:      service.hostname,
+			Labels:        service.labels,
+			StopSignal:    service.stopSignal,
+			StopTimeout:   service.stopTimeout,
+			Tty:           service.tty,
+			Volumes:       service.volumes,
+			Ports:         service.ports,
+			RestartPolicy: service.restart,
+			Healthcheck:   service.healthcheck,
+		}
+		// Compose puts services on a bridge of the project unless they set a network mode
+		if service.networkMode == "" {
+			profile.Network = &NetworkConfig{}
+		}
+		for _, dependency := range service.dependsOn {
+			containerName, ok := containerNames[dependency]
+			if !ok {
+				return profile, nil, fmt.Errorf("compose service %v depends on unknown service %v", service.service, dependency)
+			}
+			cont.DependsOn = append(cont.DependsOn, containerName)
+		}
+
+		hostConfig := map[string]interface{}{}
+		if service.privileged {
+			hostConfig["privileged"] = true
+		}
+		if service.networkMode != "" {
+			hostConfig["networkmode"] = service.networkMode
+		}
+		if service.ipcMode != "" {
+			hostConfig["ipcmode"] = service.ipcMode
+		}
+		if len(service.devices) > 0 {
+			var devices []map[string]string
+			for _, device := range service.devices {
+				devices = append(devices, map[string]string{
+					"pathonhost":        device.PathOnHost,
+					"pathincontainer":   device.PathInContainer,
+					"cgrouppermissions": device.CgroupPermissions,
+				})
+			}
+			hostConfig["resources"] = map[string]interface{}{"devices": devices}
+		}
+		if len(hostConfig) > 0 {
+			cont.HostConfig = hostConfig
+		}
+		profile.Containers = append(profile.Containers, cont)
+	}
+
+	// Order the containers so dependencies are started first
+	containers := Containers{}
+	for _, cont := range profile.Containers {
+		containers.Containers = append(containers.Containers, Container{Name: cont.Name, DependsOn: cont.DependsOn})
+	}
+	if err := containers.SortByDependencies(); err != nil {
+		return profile, nil, err
+	}
+	order := map[string]int{}
+	for i, cont := range containers.Containers {
+		order[cont.Name] = i
+	}
+	sort.SliceStable(profile.Containers, func(i, j int) bool {
+		return order[profile.Containers[i].Name] < order[profile.Containers[j].Name]
+	})
+
+	return profile, envFiles, nil
+}
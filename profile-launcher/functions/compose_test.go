@@ -0,0 +1,222 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	validCompose = `version: "3"
+services:
+  Server:
+    image: server:dev
+    command: --port 9000 --model resnet
+    working_dir: /models
+    user: "1000:1000"
+    hostname: server
+    labels:
+      - app=demo
+    stop_signal: SIGINT
+    stop_grace_period: 1m30s
+    tty: true
+    restart: on-failure:3
+    healthcheck:
+      test: ["CMD-SHELL", "curl -f http://localhost:9000"]
+      interval: 10s
+      retries: 3
+    environment:
+      - MODEL=resnet
+    ports:
+      - "9000:9000"
+      - target: 5000
+        published: 5001
+        protocol: udp
+  client:
+    image: test:dev
+    container_name: Client
+    entrypoint: ["/script/entrypoint.sh", "--loop"]
+    env_file: client.env
+    environment:
+      TEST_ENV: 456
+      MODEL_DIR: ${COMPOSE_TEST_MODELS:-/models}
+      DB_URL: postgres://${COMPOSE_TEST_MISSING}/db
+      PRICE: $$5
+    volumes:
+      - ./results:/tmp/results
+      - ${COMPOSE_TEST_DATA}:/data
+      - ${COMPOSE_TEST_MISSING}:/missing
+      - cache:/cache
+      - /data
+      - ~/cache:/home-cache
+      - ./logs:/logs:ro,rw
+      - type: bind
+        source: ./models
+        target: /models
+        read_only: true
+        bind:
+          propagation: rslave
+      - type: tmpfs
+        target: /scratch
+        tmpfs:
+          size: 64m
+    devices:
+      - /dev/video0:/dev/video0
+    network_mode: host
+    privileged: true
+    depends_on:
+      - Server
+volumes:
+  cache:`
+	buildOnlyCompose = `services:
+  client:
+    build: .`
+	unknownDependencyCompose = `services:
+  client:
+    image: test:dev
+    depends_on:
+      - server`
+	clientEnvFile = `# comment
+TEST_ENV=123
+TEST_ENV2=abc
+`
+)
+
+// TestImportCompose: test converting a compose file into a profile
+func TestImportCompose(t *testing.T) {
+	tests := []struct {
+		name            string
+		compose         string
+		expectedErr     bool
+		expectedDropped []string
+	}{
+		{"valid compose file", validCompose, false, []string{
+			"services.client.environment.DB_URL (COMPOSE_TEST_MISSING not set)",
+			"services.client.volumes.${COMPOSE_TEST_MISSING}:/missing (COMPOSE_TEST_MISSING not set)",
+			"services.client.volumes./data (anonymous volume)", "services.client.volumes../logs:/logs:ro,rw (Volume ./logs:/logs:ro,rw can't be both ro and rw)", "volumes"}},
+		{"invalid build only service", buildOnlyCompose, true, nil},
+		{"invalid unknown dependency", unknownDependencyCompose, true, nil},
+		{"invalid compose format", "invalid", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			composeDir := t.TempDir()
+			home, err := os.UserHomeDir()
+			require.NoError(t, err)
+			outputDir := filepath.Join(composeDir, "profile")
+			composePath := filepath.Join(composeDir, "docker-compose.yaml")
+			require.NoError(t, os.WriteFile(composePath, []byte(tt.compose), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(composeDir, "client.env"), []byte(clientEnvFile), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(composeDir, ".env"), []byte("COMPOSE_TEST_DATA=./data\n"), 0644))
+
+			hasError := false
+			report, err := ImportCompose(composePath, outputDir)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+			require.Equal(t, tt.expectedDropped, report.Dropped)
+
+			// The generated profile must load with the launcher
+			containersArray, err := GetYamlConfig(outputDir)
+			require.NoError(t, err)
+			require.NoError(t, containersArray.GetEnv(outputDir))
+			require.Len(t, containersArray.Containers, 2)
+			// Imported containers launch without --inputsrc
+			require.NoError(t, containersArray.SetInputSrc())
+
+			server := containersArray.Containers[0]
+			require.Equal(t, "Server", server.Name)
+			require.Equal(t, []string{"MODEL=resnet"}, server.Envs)
+			require.Equal(t, CommandArgs{"--port", "9000", "--model", "resnet"}, server.Command)
+			require.Equal(t, "/models", server.WorkingDir)
+			require.Equal(t, "1000:1000", server.User)
+			require.Equal(t, "server", server.Hostname)
+			require.Equal(t, map[string]string{"app": "demo"}, server.Labels)
+			require.Equal(t, "SIGINT", server.StopSignal)
+			require.Equal(t, 90, *server.StopTimeout)
+			require.True(t, server.Tty)
+			require.Equal(t, []string{"9000:9000", "5001:5000/udp"}, server.Ports)
+			require.Equal(t, "on-failure:3", server.RestartPolicy)
+			require.Equal(t, &Healthcheck{Test: HealthcheckTest{"CMD-SHELL", "curl -f http://localhost:9000"}, Interval: "10s", Retries: 3}, server.Healthcheck)
+			// Compose services without a network mode share a bridge
+			require.Equal(t, &NetworkConfig{}, containersArray.Network)
+
+			client := containersArray.Containers[1]
+			require.Equal(t, "Client", client.Name)
+			require.Equal(t, "test:dev", client.DockerImage)
+			require.Equal(t, CommandArgs{"/script/entrypoint.sh", "--loop"}, client.Entrypoint)
+			require.Equal(t, []string{"TEST_ENV=456", "TEST_ENV2=abc", "MODEL_DIR=/models", "PRICE=$5"}, client.Envs)
+			require.Equal(t, []VolumeSpec{
+				{Type: mount.TypeBind, Source: filepath.Join(composeDir, "results"), Target: "/tmp/results"},
+				{Type: mount.TypeBind, Source: filepath.Join(composeDir, "data"), Target: "/data"},
+				{Type: mount.TypeVolume, Source: "cache", Target: "/cache"},
+				{Type: mount.TypeBind, Source: filepath.Join(home, "cache"), Target: "/home-cache"},
+				{Type: mount.TypeBind, Source: filepath.Join(composeDir, "models"), Target: "/models", ReadOnly: true, Bind: &BindOptions{Propagation: mount.PropagationRSlave}},
+				{Type: mount.TypeTmpfs, Target: "/scratch", Tmpfs: &TmpfsOptions{Size: "64m"}},
+			}, client.Volumes)
+			require.Equal(t, []string{"Server"}, client.DependsOn)
+			require.True(t, client.HostConfig.Privileged)
+			require.Equal(t, container.NetworkMode("host"), client.HostConfig.NetworkMode)
+			require.Equal(t, []container.DeviceMapping{{PathOnHost: "/dev/video0", PathInContainer: "/dev/video0", CgroupPermissions: "rwm"}}, client.HostConfig.Devices)
+
+			// A second import must not overwrite the profile
+			_, err = ImportCompose(composePath, outputDir)
+			require.Error(t, err)
+		})
+	}
+}
+
+// TestComposeInterpolate: test resolving compose variables
+func TestComposeInterpolate(t *testing.T) {
+	t.Setenv("COMPOSE_TEST_SET", "host")
+	t.Setenv("COMPOSE_TEST_EMPTY", "")
+	vars := composeVariables{dotEnv: map[string]string{"COMPOSE_TEST_SET": "dotenv", "COMPOSE_TEST_DOTENV": "file"}}
+
+	tests := []struct {
+		name            string
+		value           string
+		expectedValue   string
+		expectedMissing []string
+	}{
+		{"valid braces", "${COMPOSE_TEST_SET}/data", "host/data", nil},
+		{"valid plain", "$COMPOSE_TEST_DOTENV:/data", "file:/data", nil},
+		{"valid default when unset or empty", "${COMPOSE_TEST_EMPTY:-a}${COMPOSE_TEST_UNSET:-b}", "ab", nil},
+		{"valid default when unset", "${COMPOSE_TEST_EMPTY-a}${COMPOSE_TEST_UNSET-b}", "b", nil},
+		{"valid alternative", "${COMPOSE_TEST_SET:+on}${COMPOSE_TEST_UNSET+off}", "on", nil},
+		{"valid escaped dollar", "$$COMPOSE_TEST_SET", "$COMPOSE_TEST_SET", nil},
+		{"invalid unset", "${COMPOSE_TEST_UNSET}:/data", ":/data", []string{"COMPOSE_TEST_UNSET"}},
+		{"invalid required empty", "${COMPOSE_TEST_EMPTY:?needed}", "", []string{"COMPOSE_TEST_EMPTY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, missing := vars.interpolate(tt.value)
+			require.Equal(t, tt.expectedValue, value)
+			require.Equal(t, tt.expectedMissing, missing)
+		})
+	}
+}
@@ -18,25 +18,32 @@
 package functions
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"slices"
 	"strings"
 
-	"github.com/docker/docker/api/types/mount"
 	"gopkg.in/yaml.v3"
 )
 
 func GetYamlConfig(configDir string) (Containers, error) {
-	profileConfigPath := filepath.Join(configDir, "profile_config.yaml")
+	profileConfigPath := filepath.Join(configDir, ProfileConfigFile)
 	contents, err := os.ReadFile(profileConfigPath)
 	if err != nil {
 		return Containers{}, fmt.Errorf("Unable to read config file: %v, error: %v",
 			configDir, err)
 	}
 
+	// Profiles built on other profiles are merged first
+	inheritance := profileInheritance{}
+	if yaml.Unmarshal(contents, &inheritance) == nil && (inheritance.Extends != "" || len(inheritance.Include) > 0) {
+		if contents, err = RenderYamlConfig(configDir); err != nil {
+			return Containers{}, err
+		}
+	}
+
 	containersArray := Containers{}
 	err = yaml.Unmarshal(contents, &containersArray)
 	if err != nil {
@@ -46,116 +53,357 @@ func GetYamlConfig(configDir string) (Containers, error) {
 	return containersArray, nil
 }
 
+// Load the env file of each container, Envs set in the config win over it.
+// Env files inherited from another profile have an absolute path.
 func (containerArray *Containers) GetEnv(configDir string) error {
 	for i, cont := range containerArray.Containers {
-		profileConfigPath := filepath.Join(configDir, cont.EnvironmentVariableFiles)
+		profileConfigPath := cont.EnvironmentVariableFiles
+		if !filepath.IsAbs(profileConfigPath) {
+			profileConfigPath = filepath.Join(configDir, cont.EnvironmentVariableFiles)
+		}
 		contents, err := os.ReadFile(profileConfigPath)
 		if err != nil {
 			err = fmt.Errorf("Unable to read config file: %v, error: %v",
 				configDir, err)
 			return err
 		}
-		containerArray.Containers[i].Envs = strings.Split(string(contents[:]), "\n")
-		// Set Target Device ENV
-		if containerArray.TargetDevice != "" {
-			containerArray.Containers[i].Envs = append(containerArray.Containers[i].Envs, containerArray.TargetDevice)
+		envMap := NewEnvMap(ParseEnvFile(string(contents)))
+		for _, env := range cont.Envs {
+			key, value, _ := strings.Cut(env, "=")
+			envMap.Set(key, value)
 		}
+		containerArray.Containers[i].Envs = envMap.Envs()
 	}
 	return nil
 }
 
+// Apply -e values, Name:KEY=VALUE targets a single container and
+// KEY=VALUE applies to all of them. A lone KEY takes the host value and
+// !KEY unsets the env.
 func (containerArray *Containers) OverrideEnv(envOverrides []string) error {
-	for contIndex, cont := range containerArray.Containers {
-		for _, override := range envOverrides {
-			notFound := true
-			if override != "" {
-				overrideArray := strings.Split(override, "=")
-				if len(overrideArray) != 2 {
-					return fmt.Errorf("env format incorrect, ensure env is EnvName=Value.")
-				}
-				for envIndex, env := range cont.Envs {
-					if strings.Contains(env, overrideArray[0]+"=") {
-						containerArray.Containers[contIndex].Envs[envIndex] = override
-						notFound = false
-					}
-				}
-				if notFound {
-					containerArray.Containers[contIndex].Envs = append(containerArray.Containers[contIndex].Envs, override)
-				}
+	for _, envOverride := range envOverrides {
+		if envOverride == "" {
+			continue
+		}
+		name, override, found := cutContainerPrefix(envOverride, nil)
+		if found {
+			if _, err := containerArray.GetContainer(name); err != nil {
+				return fmt.Errorf("Env %v: %v", envOverride, err)
+			}
+		}
+		key, value, unset, err := ParseEnvOverride(override)
+		if err != nil {
+			return err
+		}
+
+		for contIndex, _ := range containerArray.Containers {
+			cont := &containerArray.Containers[contIndex]
+			if found && cont.Name != name {
+				continue
+			}
+			if unset {
+				cont.UnsetEnv(key)
+			} else {
+				cont.SetEnv(key, value)
 			}
 		}
 	}
 	return nil
 }
 
-// Setup volume mounts for the countainer
+// Setup volume mounts for the countainer, -v values prefixed with Name:
+// only mount into that container
 func (containerArray *Containers) SetVolumes(volumes []string) error {
-	// First create the mounts from the volume inputs
-	var volumeMountParam []mount.Mount
+	// First parse the volume inputs
+	var volumeParams []VolumeSpec
+	containerVolumes := map[string][]VolumeSpec{}
 	for _, vol := range volumes {
-		tmpVol, err := CreateVolumeMount(vol)
+		name, volume, found := cutContainerPrefix(vol, func(volume string) bool {
+			_, err := ParseVolume(volume)
+			return err == nil
+		})
+		spec, err := ParseVolume(volume)
 		if err != nil {
-			return fmt.Errorf("Failed to create volume mount")
+			return fmt.Errorf("Failed to create volume mount: %v", err)
+		}
+		if !found {
+			volumeParams = append(volumeParams, spec)
+			continue
 		}
-		volumeMountParam = append(volumeMountParam, tmpVol)
+		if _, err := containerArray.GetContainer(name); err != nil {
+			return fmt.Errorf("Volume %v: %v", vol, err)
+		}
+		containerVolumes[name] = append(containerVolumes[name], spec)
 	}
 
-	// Second get the volumes found in the config yaml
+	// Second get the volumes found in the config yaml, then append any input volumes
 	for contIndex, cont := range containerArray.Containers {
-		for _, vol := range cont.Volumes {
-			tmpVol, err := CreateVolumeMount(vol)
-			if err != nil {
-				return fmt.Errorf("Failed to create volume mount")
+		specs := append(append(cont.Volumes, volumeParams...), containerVolumes[cont.Name]...)
+		for _, spec := range specs {
+			if warning := spec.namedVolumeWarning(); warning != "" {
+				fmt.Println("Warning:", warning)
+			}
+			if err := spec.AddTo(&containerArray.Containers[contIndex].HostConfig); err != nil {
+				return fmt.Errorf("Failed to create volume mount: %v", err)
 			}
-			containerArray.Containers[contIndex].HostConfig.Mounts = append(containerArray.Containers[contIndex].HostConfig.Mounts, tmpVol)
 		}
-		// Append any input volumes to the config yaml volume list
-		containerArray.Containers[contIndex].HostConfig.Mounts = append(containerArray.Containers[contIndex].HostConfig.Mounts, volumeMountParam...)
 	}
 
 	return nil
 }
 
-// Setup device mounts and ENV based on the targe device input
+// Apply --target_device values, Name=Device targets a single container and
+// a plain device replaces the profile wide target device
+func (containerArray *Containers) SetTargetDeviceOverrides(targetDevices []string) error {
+	for _, targetDevice := range targetDevices {
+		if targetDevice == "" {
+			continue
+		}
+		name, device, found := cutContainerName(targetDevice)
+		if !found {
+			containerArray.TargetDevice = targetDevice
+			continue
+		}
+		cont, err := containerArray.GetContainer(name)
+		if err != nil {
+			return fmt.Errorf("Target device %v: %v", targetDevice, err)
+		}
+		cont.TargetDevice = device
+	}
+	return nil
+}
+
+var containerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Split a Name=Value CLI value targeting a single container
+func cutContainerName(value string) (string, string, bool) {
+	name, rest, found := strings.Cut(value, "=")
+	if !found || !containerNamePattern.MatchString(name) {
+		return "", value, false
+	}
+	return name, rest, true
+}
+
+// Split a Name:Value CLI value targeting a single container. The value must
+// still be valid without the prefix, so colons of the value itself such as
+// the one of a host:container volume aren't taken for a container name.
+func cutContainerPrefix(value string, valid func(string) bool) (string, string, bool) {
+	name, rest, found := strings.Cut(value, ":")
+	if !found || !containerNamePattern.MatchString(name) || (valid != nil && !valid(rest)) {
+		return "", value, false
+	}
+	return name, rest, true
+}
+
+// Find a container of the profile by name
+func (containerArray *Containers) GetContainer(name string) (*Container, error) {
+	for contIndex, cont := range containerArray.Containers {
+		if cont.Name == name {
+			return &containerArray.Containers[contIndex], nil
+		}
+	}
+	return nil, fmt.Errorf("container %v not found in the profile", name)
+}
+
+// Setup device mounts and ENV based on the targe device input, a container
+// uses its own target device and falls back on the profile wide one
 func (containerArray *Containers) SetTargetDevice() error {
-	if containerArray.TargetDevice == "" {
-		containerArray.SetPrivileged()
-	} else if containerArray.TargetDevice == "CPU" {
-		// CPU do nothing
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		if containerArray.Privileged {
+			// Privileged mode is opt-in only, it exposes every host device
+			cont.HostConfig.Privileged = true
+		}
+		if cont.TargetDevice == "" {
+			cont.TargetDevice = containerArray.TargetDevice
+		}
+		if err := cont.setTargetDevice(!containerArray.Export); err != nil {
+			return fmt.Errorf("Container %v: %v", cont.Name, err)
+		}
+	}
+	return nil
+}
+
+// Setup device mounts and the TARGET_DEVICE ENV of a single container
+func (cont *Container) SetTargetDevice() error {
+	return cont.setTargetDevice(true)
+}
+
+// Without lookupGpus the GPUs are left out, they belong to the host the
+// profile is exported for
+func (cont *Container) setTargetDevice(lookupGpus bool) error {
+	if cont.TargetDevice == "" {
+		if !lookupGpus {
+			return nil
+		}
+		// No target device, give access to any GPU on the host
+		return cont.SetGpuDevices(false)
+	}
+
+	// Map the union of the device nodes of every device the target uses
+	targetDevice, err := ParseTargetDevice(cont.TargetDevice)
+	if err != nil {
+		return err
+	}
+	hostTarget := targetDevice
+	if !lookupGpus {
+		hostTarget.Devices = slices.DeleteFunc(slices.Clone(targetDevice.Devices), func(device DeviceName) bool { return device.Name == "GPU" })
+		if hostTarget.Plugin == PluginAuto && len(hostTarget.Devices) == 0 {
+			// AUTO would look up the GPUs of this host
+			hostTarget.Devices = []DeviceName{{Name: "CPU", Index: -1}}
+		}
+	}
+	deviceNodes, cgroupRules, err := hostTarget.HostDevices()
+	if err != nil {
+		return err
+	}
+	for _, deviceNode := range deviceNodes {
+		cont.AddHostDevice(deviceNode.Path)
+	}
+	if len(deviceNodes) > 0 {
+		cont.AddGroups(deviceGroupIDs(deviceNodes)...)
+	}
+	for _, cgroupRule := range cgroupRules {
+		cont.AddDeviceCgroupRule(cgroupRule)
+	}
+	cont.SetEnv("TARGET_DEVICE", cont.TargetDevice)
+	return nil
+}
+
+// Map every GPU device node into the container so it doesn't need privileged mode
+func (cont *Container) SetGpuDevices(required bool) error {
+	gpuNodes, cgroupRule, err := gpuHostDevices(-1, required)
+	if err != nil {
+		return err
+	}
+	if len(gpuNodes) == 0 {
 		return nil
-	} else if containerArray.TargetDevice == "GPU" ||
-		strings.Contains(containerArray.TargetDevice, "MULTI") ||
-		containerArray.TargetDevice == "AUTO" {
-		// GPU set to privileged so we can access all GPU
-		containerArray.SetPrivileged()
-	} else if strings.Contains(containerArray.TargetDevice, "GPU.") {
-		// GPU.X set access to a specific GPU device and set the correct ENV
-		TargetDeviceArray := strings.Split(containerArray.TargetDevice, ".")
-		DeviceNum, errNum := strconv.Atoi(TargetDeviceArray[1])
-		if errNum != nil {
-			return errNum
-		}
-		TargetDeviceNum := 128 + DeviceNum
-		TargetGpu := strconv.Itoa(TargetDeviceNum)
-		// Set GPU Device
-		containerArray.SetHostDevice("/dev/dri/renderD" + TargetGpu)
-	} else {
-		return fmt.Errorf("Target device not supported")
 	}
+
+	for _, gpuNode := range gpuNodes {
+		cont.AddHostDevice(gpuNode.Path)
+	}
+	cont.AddGroups(deviceGroupIDs(gpuNodes)...)
+	cont.AddDeviceCgroupRule(cgroupRule)
 	return nil
 }
 
-// Setup devices and other mounts based on the inputsrc
-func (containerArray *Containers) SetInputSrc() error {
-	if containerArray.InputSrc == "" {
-		return errors.New("InputSrc was not set. Exiting profile launcher.")
-	} else if strings.Contains(containerArray.InputSrc, "/video") {
-		containerArray.SetHostDevice(containerArray.InputSrc)
+// Set an ENV of the container, replacing any existing value for the key
+func (cont *Container) SetEnv(key string, value string) {
+	envMap := NewEnvMap(cont.Envs)
+	envMap.Set(key, value)
+	cont.Envs = envMap.Envs()
+}
+
+// Remove an ENV of the container
+func (cont *Container) UnsetEnv(key string) {
+	envMap := NewEnvMap(cont.Envs)
+	envMap.Unset(key)
+	cont.Envs = envMap.Envs()
+}
+
+// Apply --inputsrc values, Name=Source targets a single container and a
+// plain source replaces the profile wide input source
+func (containerArray *Containers) SetInputSrcOverrides(inputSrcs []string) error {
+	for _, inputSrc := range inputSrcs {
+		if inputSrc == "" {
+			continue
+		}
+		name, source, found := cutContainerName(inputSrc)
+		if !found {
+			containerArray.InputSrc = inputSrc
+			continue
+		}
+		cont, err := containerArray.GetContainer(name)
+		if err != nil {
+			return fmt.Errorf("Input source %v: %v", inputSrc, err)
+		}
+		if cont.InputSrc == nil {
+			cont.InputSrc = &ContainerInput{Required: true}
+		} else if cont.InputSrc.Disabled {
+			return fmt.Errorf("Input source %v: container %v takes no input", inputSrc, name)
+		}
+		cont.InputSrc.Source = source
 	}
+	return nil
+}
 
+// Setup devices and other mounts based on the inputsrc, a container uses its
+// own input source and falls back on the profile wide one
+func (containerArray *Containers) SetInputSrc() error {
 	for contIndex, _ := range containerArray.Containers {
-		containerArray.Containers[contIndex].Envs = append(containerArray.Containers[contIndex].Envs, "INPUTSRC="+containerArray.InputSrc)
+		cont := &containerArray.Containers[contIndex]
+		if cont.InputSrc == nil {
+			// Containers without an InputSrc section need the profile input
+			cont.InputSrc = &ContainerInput{Required: true}
+		}
+		if cont.InputSrc.Disabled {
+			continue
+		}
+		if cont.InputSrc.Source == "" {
+			cont.InputSrc.Source = containerArray.InputSrc
+		}
+		if cont.InputSrc.Source == "" {
+			if cont.InputSrc.Required {
+				return fmt.Errorf("InputSrc was not set for container %v. Exiting profile launcher.", cont.Name)
+			}
+			continue
+		}
+
+		inputSource, err := ParseInputSource(cont.InputSrc.Source)
+		if err != nil {
+			return fmt.Errorf("Container %v: %v", cont.Name, err)
+		}
+		cont.SetInputSource(cont.InputSrc.GetEnvName(), inputSource)
+	}
+
+	return nil
+}
+
+// Order the containers so every container starts after the containers it
+// depends on, keeping the declaration order otherwise
+func (containerArray *Containers) SortByDependencies() error {
+	names := map[string]bool{}
+	for _, cont := range containerArray.Containers {
+		names[cont.Name] = true
+	}
+	for _, cont := range containerArray.Containers {
+		for _, dependency := range cont.DependsOn {
+			if !names[dependency] {
+				return fmt.Errorf("Container %v depends on unknown container %v", cont.Name, dependency)
+			}
+		}
 	}
 
+	var sorted []Container
+	started := map[string]bool{}
+	remaining := append([]Container{}, containerArray.Containers...)
+	for len(remaining) > 0 {
+		next := -1
+		for i, cont := range remaining {
+			ready := true
+			for _, dependency := range cont.DependsOn {
+				if !started[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for _, cont := range remaining {
+				cycle = append(cycle, cont.Name)
+			}
+			return fmt.Errorf("Dependency cycle between containers %v", strings.Join(cycle, ", "))
+		}
+		sorted = append(sorted, remaining[next])
+		started[remaining[next].Name] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	containerArray.Containers = sorted
 	return nil
 }
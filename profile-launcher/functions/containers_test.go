@@ -18,6 +18,7 @@
 package functions
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/docker/api/types/container"
@@ -90,7 +91,14 @@ func TestOverrideEnv(t *testing.T) {
 		{"valid new env", false, []string{"NEW_ENV=test"}, []string{"TEST_ENV=123", "NEW_ENV=test"}},
 		{"invalid env overrides", true, []string{"TEST_ENV"}, []string{}},
 		{"invalid new env", true, []string{"NEW_ENV"}, []string{}},
+		{"valid exact key match", false, []string{"ENV=abc"}, []string{"TEST_ENV=123", "ENV=abc"}},
+		{"valid value with equals", false, []string{"TEST_ENV=a=b"}, []string{"TEST_ENV=a=b"}},
+		{"valid env from host", false, []string{"HOST_ENV"}, []string{"TEST_ENV=123", "HOST_ENV=host"}},
+		{"valid unset", false, []string{"!TEST_ENV"}, nil},
+		{"valid repeated override", false, []string{"NEW_ENV=a", "NEW_ENV=b"}, []string{"TEST_ENV=123", "NEW_ENV=b"}},
+		{"invalid empty name", true, []string{"=abc"}, []string{}},
 	}
+	t.Setenv("HOST_ENV", "host")
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tmpContainers := CreateTestContainers("", "")
@@ -129,9 +137,9 @@ func TestSetVolumes(t *testing.T) {
 		expectedVolumes    []mount.Mount
 		expectedContainers Containers
 	}{
-		{"valid with no input volumes", false, []string{}, []mount.Mount{volumeMount1}, Containers{Containers: []Container{{Volumes: []string{"volume:volume"}}}}},
-		{"valid with input volumes", false, []string{"test:test"}, []mount.Mount{volumeMount1, volumeMount2}, Containers{Containers: []Container{{Volumes: []string{"volume:volume"}}}}},
-		{"invalid volume in config", true, []string{}, []mount.Mount{}, Containers{Containers: []Container{{Volumes: []string{"test"}}}}},
+		{"valid with no input volumes", false, []string{}, []mount.Mount{volumeMount1}, Containers{Containers: []Container{{Volumes: []VolumeSpec{{Type: mount.TypeVolume, Source: "volume", Target: "volume"}}}}}},
+		{"valid with input volumes", false, []string{"test:test"}, []mount.Mount{volumeMount1, volumeMount2}, Containers{Containers: []Container{{Volumes: []VolumeSpec{{Type: mount.TypeVolume, Source: "volume", Target: "volume"}}}}}},
+		{"invalid volume in config", true, []string{}, []mount.Mount{}, Containers{Containers: []Container{{Volumes: []VolumeSpec{{Target: "test"}}}}}},
 		{"invalid volume param", true, []string{"test"}, []mount.Mount{}, CreateTestContainers("", "")},
 	}
 	for _, tt := range tests {
@@ -152,25 +160,117 @@ func TestSetVolumes(t *testing.T) {
 	}
 }
 
+// TestOverrideEnvPerContainer: test env overrides targeting a single container
+func TestOverrideEnvPerContainer(t *testing.T) {
+	tests := []struct {
+		name              string
+		overrideEnv       []string
+		expectedErr       bool
+		expectedClientEnv []string
+		expectedServerEnv []string
+	}{
+		{"valid single container", []string{"Client:BATCH=4"}, false, []string{"TEST_ENV=123", "BATCH=4"}, []string{"TEST_ENV=123"}},
+		{"valid global and single container", []string{"TEST_ENV=all", "Server:TEST_ENV=server"}, false, []string{"TEST_ENV=all"}, []string{"TEST_ENV=server"}},
+		{"valid colon in value is not a container name", []string{"URL=rtsp://127.0.0.1:8554/camera_0"}, false, []string{"TEST_ENV=123", "URL=rtsp://127.0.0.1:8554/camera_0"}, []string{"TEST_ENV=123", "URL=rtsp://127.0.0.1:8554/camera_0"}},
+		{"invalid unknown container", []string{"Unknown:BATCH=4"}, true, nil, nil},
+		{"invalid env format", []string{"Client:BATCH"}, true, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", "")
+			for contIndex, _ := range tmpContainers.Containers {
+				tmpContainers.Containers[contIndex].Envs = []string{"TEST_ENV=123"}
+			}
+
+			hasError := false
+			if err := tmpContainers.OverrideEnv(tt.overrideEnv); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedClientEnv, tmpContainers.Containers[0].Envs)
+				require.Equal(t, tt.expectedServerEnv, tmpContainers.Containers[1].Envs)
+			}
+		})
+	}
+}
+
+// TestSetVolumesPerContainer: test volume flags targeting a single container
+func TestSetVolumesPerContainer(t *testing.T) {
+	profileMount, err := CreateVolumeMount("./test-profile:/test-profile")
+	require.NoError(t, err)
+	modelsMount, err := CreateVolumeMount("./models:/models:ro")
+	require.NoError(t, err)
+	cacheMount, err := CreateVolumeMount("cache:/cache")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		volumes              []string
+		expectedErr          bool
+		expectedClientMounts []mount.Mount
+		expectedServerMounts []mount.Mount
+	}{
+		{"valid single container", []string{"Server:./models:/models:ro"}, false, []mount.Mount{profileMount}, []mount.Mount{profileMount, modelsMount}},
+		{"valid named volume is not a container name", []string{"cache:/cache"}, false, []mount.Mount{profileMount, cacheMount}, []mount.Mount{profileMount, cacheMount}},
+		{"valid named volume in single container", []string{"Client:cache:/cache"}, false, []mount.Mount{profileMount, cacheMount}, []mount.Mount{profileMount}},
+		{"invalid unknown container", []string{"Unknown:./models:/models"}, true, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", "")
+
+			hasError := false
+			if err := tmpContainers.SetVolumes(tt.volumes); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedClientMounts, tmpContainers.Containers[0].HostConfig.Mounts)
+				require.Equal(t, tt.expectedServerMounts, tmpContainers.Containers[1].HostConfig.Mounts)
+			}
+		})
+	}
+}
+
 // SetTargetDevice: test setting target device
 func TestSetTargetDevice(t *testing.T) {
+	CreateFakeDevTree(t, "dri/card0", "dri/renderD128", "dri/by-path/pci-0000:00:02.0-render", "accel/accel0")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeGpu(t, fakeSysRoot, "card0", "renderD128", "0000:00:02.0", intelVendorID, "i915")
+	AddFakeNpu(t, fakeSysRoot, "accel0", "0000:00:0b.0")
+	allGpuDevices := []container.DeviceMapping{
+		{PathOnHost: "/dev/dri/card0", PathInContainer: "/dev/dri/card0", CgroupPermissions: "rwm"},
+		{PathOnHost: "/dev/dri/renderD128", PathInContainer: "/dev/dri/renderD128", CgroupPermissions: "rwm"},
+	}
+
 	tests := []struct {
 		name            string
 		expectedErr     bool
 		setTargetDevice string
+		setPrivileged   bool
 		isPrivileged    bool
 		setHostDevice   []container.DeviceMapping
 		hasDevices      bool
 	}{
-		{"valid no target device", false, "", true, []container.DeviceMapping{}, false},
-		{"valid CPU target device", false, "CPU", false, []container.DeviceMapping{}, false},
-		{"valid GPU target device", false, "GPU", true, []container.DeviceMapping{}, false},
-		{"valid GPU.0 target device", false, "GPU.0", false, []container.DeviceMapping{{PathOnHost: "/dev/dri/renderD128", PathInContainer: "/dev/dri/renderD128", CgroupPermissions: "rwm"}}, true},
-		{"invalid target device", true, "invalid", false, []container.DeviceMapping{}, false},
+		{"valid no target device", false, "", false, false, allGpuDevices, true},
+		{"valid CPU target device", false, "CPU", false, false, []container.DeviceMapping{}, false},
+		{"valid GPU target device", false, "GPU", false, false, allGpuDevices, true},
+		{"valid MULTI target device", false, "MULTI:GPU,CPU", false, false, allGpuDevices, true},
+		{"valid GPU.0 target device", false, "GPU.0", false, false, []container.DeviceMapping{{PathOnHost: "/dev/dri/renderD128", PathInContainer: "/dev/dri/renderD128", CgroupPermissions: "rwm"}}, true},
+		{"valid privileged opt-in", false, "GPU", true, true, allGpuDevices, true},
+		{"valid NPU target device", false, "NPU", false, false, []container.DeviceMapping{{PathOnHost: "/dev/accel/accel0", PathInContainer: "/dev/accel/accel0", CgroupPermissions: "rwm"}}, true},
+		{"valid HETERO target device", false, "HETERO:GPU.0,NPU,CPU", false, false, []container.DeviceMapping{{PathOnHost: "/dev/dri/renderD128", PathInContainer: "/dev/dri/renderD128", CgroupPermissions: "rwm"}, {PathOnHost: "/dev/accel/accel0", PathInContainer: "/dev/accel/accel0", CgroupPermissions: "rwm"}}, true},
+		{"valid AUTO target device", false, "AUTO", false, false, append(append([]container.DeviceMapping{}, allGpuDevices...), container.DeviceMapping{PathOnHost: "/dev/accel/accel0", PathInContainer: "/dev/accel/accel0", CgroupPermissions: "rwm"}), true},
+		{"invalid HETERO without devices", true, "HETERO", false, false, []container.DeviceMapping{}, false},
+		{"invalid missing NPU.1 target device", true, "NPU.1", false, false, []container.DeviceMapping{}, false},
+		{"invalid missing GPU.1 target device", true, "GPU.1", false, false, []container.DeviceMapping{}, false},
+		{"invalid target device", true, "invalid", false, false, []container.DeviceMapping{}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tmpContainers := CreateTestContainers("", tt.setTargetDevice)
+			tmpContainers.Privileged = tt.setPrivileged
 			hasError := false
 			err := tmpContainers.SetTargetDevice()
 			if err != nil {
@@ -181,25 +281,169 @@ func TestSetTargetDevice(t *testing.T) {
 				require.Equal(t, cont.HostConfig.Privileged, tt.isPrivileged)
 				if tt.hasDevices {
 					require.Equal(t, cont.HostConfig.Devices, tt.setHostDevice)
+					require.NotEmpty(t, cont.HostConfig.GroupAdd)
+				}
+				if tt.setTargetDevice != "" && !tt.expectedErr {
+					require.Contains(t, cont.Envs, "TARGET_DEVICE="+tt.setTargetDevice)
+				}
+			}
+		})
+	}
+}
+
+// TestSetTargetDevicePerContainer: test target devices set on a single container
+func TestSetTargetDevicePerContainer(t *testing.T) {
+	CreateFakeDevTree(t, "dri/card0", "dri/renderD128", "dri/card1", "dri/renderD129")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeGpu(t, fakeSysRoot, "card0", "renderD128", "0000:00:02.0", intelVendorID, "i915")
+	AddFakeGpu(t, fakeSysRoot, "card1", "renderD129", "0000:03:00.0", intelVendorID, "i915")
 
+	tests := []struct {
+		name                  string
+		profileTargetDevice   string
+		containerTargetDevice string
+		overrides             []string
+		expectedErr           bool
+		expectedClientDevice  string
+		expectedServerDevice  string
+		expectedServerDevices []string
+	}{
+		{"valid server override from the CLI", "", "", []string{"CPU", "Server=GPU.1"}, false, "CPU", "GPU.1", []string{"/dev/dri/renderD129"}},
+		{"valid container device from the config", "CPU", "GPU.0", []string{}, false, "CPU", "GPU.0", []string{"/dev/dri/renderD128"}},
+		{"valid container device wins over the CLI profile device", "", "GPU.0", []string{"CPU"}, false, "CPU", "GPU.0", []string{"/dev/dri/renderD128"}},
+		{"valid CLI container device wins over the config", "CPU", "GPU.0", []string{"Server=GPU.1"}, false, "CPU", "GPU.1", []string{"/dev/dri/renderD129"}},
+		{"invalid unknown container", "CPU", "", []string{"Unknown=GPU.1"}, true, "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", tt.profileTargetDevice)
+			tmpContainers.Containers[1].TargetDevice = tt.containerTargetDevice
+
+			err := tmpContainers.SetTargetDeviceOverrides(tt.overrides)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, tmpContainers.SetTargetDevice())
+
+			client, server := tmpContainers.Containers[0], tmpContainers.Containers[1]
+			require.Equal(t, tt.expectedClientDevice, client.TargetDevice)
+			require.Equal(t, []string{"TARGET_DEVICE=" + tt.expectedClientDevice}, client.Envs)
+			require.Empty(t, client.HostConfig.Devices)
+			require.Equal(t, tt.expectedServerDevice, server.TargetDevice)
+			require.Equal(t, []string{"TARGET_DEVICE=" + tt.expectedServerDevice}, server.Envs)
+			var serverDevices []string
+			for _, device := range server.HostConfig.Devices {
+				serverDevices = append(serverDevices, device.PathOnHost)
+			}
+			require.Equal(t, tt.expectedServerDevices, serverDevices)
+		})
+	}
+}
+
+// TestSetTargetDeviceExport: test resolving target devices for another host
+func TestSetTargetDeviceExport(t *testing.T) {
+	CreateFakeDevTree(t, "dri/card0", "dri/renderD128", "accel/accel0")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeGpu(t, fakeSysRoot, "card0", "renderD128", "0000:00:02.0", intelVendorID, "i915")
+	AddFakeNpu(t, fakeSysRoot, "accel0", "0000:00:0b.0")
+
+	tests := []struct {
+		name            string
+		targetDevice    string
+		expectedErr     bool
+		expectedDevices []string
+	}{
+		{"valid GPU index missing on this host", "GPU.1", false, nil},
+		{"valid no target device", "", false, nil},
+		{"valid AUTO", "AUTO", false, nil},
+		{"valid HETERO keeps the NPU", "HETERO:GPU.3,NPU", false, []string{"/dev/accel/accel0"}},
+		{"invalid target device", "GPU.x", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", tt.targetDevice)
+			tmpContainers.Export = true
+			hasError := false
+			err := tmpContainers.SetTargetDevice()
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+			for _, cont := range tmpContainers.Containers {
+				var devices []string
+				for _, device := range cont.HostConfig.Devices {
+					devices = append(devices, device.PathOnHost)
+				}
+				require.Equal(t, tt.expectedDevices, devices)
+				if tt.targetDevice != "" {
+					require.Contains(t, cont.Envs, "TARGET_DEVICE="+tt.targetDevice)
 				}
 			}
 		})
 	}
 }
 
+// TestSetGpuDevices: test mapping all GPU nodes
+func TestSetGpuDevices(t *testing.T) {
+	tests := []struct {
+		name                string
+		nodes               []string
+		required            bool
+		expectedErr         bool
+		expectedDevices     int
+		expectedCgroupRules []string
+	}{
+		{"valid GPU nodes", []string{"dri/card0", "dri/renderD128"}, true, false, 2, []string{"c 226:* rmw"}},
+		{"valid no GPU not required", []string{}, false, false, 0, nil},
+		{"invalid no GPU required", []string{}, true, true, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CreateFakeDevTree(t, tt.nodes...)
+			cont := CreateTestContainers("", "").Containers[0]
+			hasError := false
+			err := cont.SetGpuDevices(tt.required)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			require.Len(t, cont.HostConfig.Devices, tt.expectedDevices)
+			require.Equal(t, tt.expectedCgroupRules, cont.HostConfig.DeviceCgroupRules)
+			require.False(t, cont.HostConfig.Privileged)
+		})
+	}
+}
+
 // TestSetInputSrc: test setting input src
 func TestSetInputSrc(t *testing.T) {
+	CreateFakeDevTree(t, "video0", "video1")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeCamera(t, fakeSysRoot, "video0", "0", "USB Camera", "1-1:1.0")
+	AddFakeCamera(t, fakeSysRoot, "video1", "1", "USB Camera", "1-1:1.0")
+	inputFile, err := filepath.Abs(filepath.Join(testConfigDir, "profile.env"))
+	require.NoError(t, err)
+
 	tests := []struct {
 		name          string
 		expectedErr   bool
 		setInputSrc   string
 		setHostDevice []container.DeviceMapping
 		hasDevices    bool
+		expectedMount []mount.Mount
+		expectedEnv   string
 	}{
-		{"valid no input src", true, "", []container.DeviceMapping{}, false},
-		{"valid USB input src", false, "/dev/video0", []container.DeviceMapping{{PathOnHost: "/dev/video0", PathInContainer: "/dev/video0", CgroupPermissions: "rwm"}}, true},
-		{"valid RTSP input src", false, "RTSP://127.0.0.1:8554/camera_0", []container.DeviceMapping{}, false},
+		{"valid no input src", true, "", []container.DeviceMapping{}, false, nil, ""},
+		{"valid USB input src", false, "/dev/video0", []container.DeviceMapping{{PathOnHost: "/dev/video0", PathInContainer: "/dev/video0", CgroupPermissions: "rwm"}}, true, nil, "INPUTSRC=/dev/video0"},
+		{"valid RTSP input src", false, "RTSP://127.0.0.1:8554/camera_0", []container.DeviceMapping{}, false, nil, "INPUTSRC=RTSP://127.0.0.1:8554/camera_0"},
+		{"valid file input src", false, filepath.Join(testConfigDir, "profile.env"), []container.DeviceMapping{}, false, []mount.Mount{{Type: mount.TypeBind, Source: inputFile, Target: "/input/profile.env", ReadOnly: true}}, "INPUTSRC=/input/profile.env"},
+		{"invalid metadata node input src", true, "/dev/video1", []container.DeviceMapping{}, false, nil, ""},
+		{"invalid missing camera input src", true, "/dev/video2", []container.DeviceMapping{}, false, nil, ""},
+		{"invalid missing file input src", true, "./missing.mp4", []container.DeviceMapping{}, false, nil, ""},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -215,6 +459,88 @@ func TestSetInputSrc(t *testing.T) {
 					require.Equal(t, cont.HostConfig.Devices, tt.setHostDevice)
 
 				}
+				if !tt.expectedErr {
+					require.Equal(t, tt.expectedMount, cont.HostConfig.Mounts)
+					require.Equal(t, []string{tt.expectedEnv}, cont.Envs)
+				}
+			}
+		})
+	}
+}
+
+// TestSetInputSrcPerContainer: test input sources declared per container
+func TestSetInputSrcPerContainer(t *testing.T) {
+	tests := []struct {
+		name              string
+		profileInputSrc   string
+		clientInput       *ContainerInput
+		serverInput       *ContainerInput
+		overrides         []string
+		expectedErr       bool
+		expectedClientEnv []string
+		expectedServerEnv []string
+	}{
+		{"valid server without input", "", &ContainerInput{Required: true}, &ContainerInput{Disabled: true}, []string{"rtsp://127.0.0.1:8554/camera_0"}, false, []string{"INPUTSRC=rtsp://127.0.0.1:8554/camera_0"}, nil},
+		{"valid server only profile", "", &ContainerInput{Disabled: true}, &ContainerInput{}, []string{}, false, nil, nil},
+		{"valid optional input given", "", &ContainerInput{Disabled: true}, &ContainerInput{EnvName: "MODEL_SRC"}, []string{"Server=videotestsrc"}, false, nil, []string{"MODEL_SRC=videotestsrc"}},
+		{"valid per container inputs", "", nil, nil, []string{"Client=rtsp://127.0.0.1:8554/camera_0", "Server=rtsp://127.0.0.1:8554/camera_1"}, false, []string{"INPUTSRC=rtsp://127.0.0.1:8554/camera_0"}, []string{"INPUTSRC=rtsp://127.0.0.1:8554/camera_1"}},
+		{"valid container source wins over the profile input", "rtsp://127.0.0.1:8554/camera_0", nil, &ContainerInput{Source: "videotestsrc"}, []string{}, false, []string{"INPUTSRC=rtsp://127.0.0.1:8554/camera_0"}, []string{"INPUTSRC=videotestsrc"}},
+		{"valid URL with query is not a container name", "", nil, &ContainerInput{Disabled: true}, []string{"http://127.0.0.1/video?name=cam"}, false, []string{"INPUTSRC=http://127.0.0.1/video?name=cam"}, nil},
+		{"invalid required input missing", "", &ContainerInput{Disabled: true}, &ContainerInput{Required: true}, []string{}, true, nil, nil},
+		{"invalid unknown container", "", nil, nil, []string{"Unknown=videotestsrc"}, true, nil, nil},
+		{"invalid input for container without input", "", nil, &ContainerInput{Disabled: true}, []string{"Server=videotestsrc"}, true, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers(tt.profileInputSrc, "")
+			tmpContainers.Containers[0].InputSrc = tt.clientInput
+			tmpContainers.Containers[1].InputSrc = tt.serverInput
+
+			hasError := false
+			err := tmpContainers.SetInputSrcOverrides(tt.overrides)
+			if err == nil {
+				err = tmpContainers.SetInputSrc()
+			}
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedClientEnv, tmpContainers.Containers[0].Envs)
+				require.Equal(t, tt.expectedServerEnv, tmpContainers.Containers[1].Envs)
+			}
+		})
+	}
+}
+
+// TestSortByDependencies: test ordering containers by their dependencies
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectedErr   bool
+		containers    []Container
+		expectedOrder []string
+	}{
+		{"valid no dependencies", false, []Container{{Name: "Client"}, {Name: "Server"}}, []string{"Client", "Server"}},
+		{"valid dependency declared later", false, []Container{{Name: "Client", DependsOn: []string{"Server"}}, {Name: "Server"}, {Name: "Other"}}, []string{"Server", "Client", "Other"}},
+		{"invalid unknown dependency", true, []Container{{Name: "Client", DependsOn: []string{"Server"}}}, nil},
+		{"invalid dependency cycle", true, []Container{{Name: "Client", DependsOn: []string{"Server"}}, {Name: "Server", DependsOn: []string{"Client"}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := Containers{Containers: tt.containers}
+			hasError := false
+			err := tmpContainers.SortByDependencies()
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				var order []string
+				for _, cont := range tmpContainers.Containers {
+					order = append(order, cont.Name)
+				}
+				require.Equal(t, tt.expectedOrder, order)
 			}
 		})
 	}
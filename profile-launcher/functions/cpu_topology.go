@@ -0,0 +1,213 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ways --cpuset-per-replica spreads replicas over the host CPUs
+const (
+	// Split the physical cores between the replicas
+	CpusetPerCore = "core"
+	// Give each replica cores and memory of a single NUMA node
+	CpusetPerNuma = "numa"
+)
+
+// Physical core of the host with its hyperthread siblings
+type CPUCore struct {
+	Node    int
+	Package int
+	Core    int
+	CPUs    []int
+}
+
+// Physical cores of the online CPUs read from sysfs
+type CPUTopology struct {
+	Cores []CPUCore
+}
+
+// CPUs and memory nodes a replica is pinned to
+type CPUAssignment struct {
+	Cpus string
+	Mems string
+}
+
+// Read the host CPU topology from /sys/devices/system
+func ReadCPUTopology() (CPUTopology, error) {
+	cpuDir := filepath.Join(sysRoot, "devices", "system", "cpu")
+	online, err := os.ReadFile(filepath.Join(cpuDir, "online"))
+	if err != nil {
+		return CPUTopology{}, fmt.Errorf("Failed to read the online CPUs %v", err)
+	}
+	cpus, err := ParseCPUList(string(online))
+	if err != nil {
+		return CPUTopology{}, fmt.Errorf("Online CPUs %v", err)
+	}
+
+	// Hosts without NUMA support have no node directory, every CPU is on node 0
+	cpuNodes := map[int]int{}
+	nodeDirs, _ := filepath.Glob(filepath.Join(sysRoot, "devices", "system", "node", "node[0-9]*"))
+	for _, nodeDir := range nodeDirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(nodeDir), "node"))
+		if err != nil {
+			continue
+		}
+		nodeCpus, err := ParseCPUList(readSysfsAttribute(filepath.Join(nodeDir, "cpulist")))
+		if err != nil {
+			continue
+		}
+		for _, cpu := range nodeCpus {
+			cpuNodes[cpu] = node
+		}
+	}
+
+	type coreKey struct{ pkg, core int }
+	cores := map[coreKey]*CPUCore{}
+	for _, cpu := range cpus {
+		topologyDir := filepath.Join(cpuDir, "cpu"+strconv.Itoa(cpu), "topology")
+		pkg, pkgErr := strconv.Atoi(readSysfsAttribute(filepath.Join(topologyDir, "physical_package_id")))
+		core, coreErr := strconv.Atoi(readSysfsAttribute(filepath.Join(topologyDir, "core_id")))
+		if pkgErr != nil || coreErr != nil {
+			return CPUTopology{}, fmt.Errorf("Failed to read the topology of CPU %v", cpu)
+		}
+		key := coreKey{pkg, core}
+		if cores[key] == nil {
+			cores[key] = &CPUCore{Node: cpuNodes[cpu], Package: pkg, Core: core}
+		}
+		cores[key].CPUs = append(cores[key].CPUs, cpu)
+	}
+
+	topology := CPUTopology{}
+	for _, core := range cores {
+		topology.Cores = append(topology.Cores, *core)
+	}
+	sort.Slice(topology.Cores, func(i, j int) bool {
+		return topology.Cores[i].CPUs[0] < topology.Cores[j].CPUs[0]
+	})
+	return topology, nil
+}
+
+// Split the cores into replicas groups of whole cores that don't overlap.
+// With CpusetPerNuma each group stays on one NUMA node and uses its memory.
+func (topology CPUTopology) Spread(replicas int, mode string) ([]CPUAssignment, error) {
+	if replicas < 1 {
+		return nil, fmt.Errorf("no replicas to pin")
+	}
+	switch mode {
+	case CpusetPerCore:
+		groups, err := splitCores(topology.Cores, replicas)
+		if err != nil {
+			return nil, err
+		}
+		var assignments []CPUAssignment
+		for _, group := range groups {
+			assignments = append(assignments, CPUAssignment{Cpus: coreCPUList(group)})
+		}
+		return assignments, nil
+	case CpusetPerNuma:
+		nodeCores := map[int][]CPUCore{}
+		var nodes []int
+		for _, core := range topology.Cores {
+			if _, ok := nodeCores[core.Node]; !ok {
+				nodes = append(nodes, core.Node)
+			}
+			nodeCores[core.Node] = append(nodeCores[core.Node], core)
+		}
+		sort.Ints(nodes)
+		// Replicas fill the nodes evenly, the first nodes take the remainder
+		var assignments []CPUAssignment
+		for nodeIndex, node := range nodes {
+			nodeReplicas := replicas / len(nodes)
+			if nodeIndex < replicas%len(nodes) {
+				nodeReplicas++
+			}
+			if nodeReplicas == 0 {
+				continue
+			}
+			groups, err := splitCores(nodeCores[node], nodeReplicas)
+			if err != nil {
+				return nil, fmt.Errorf("NUMA node %v: %v", node, err)
+			}
+			for _, group := range groups {
+				assignments = append(assignments, CPUAssignment{Cpus: coreCPUList(group), Mems: strconv.Itoa(node)})
+			}
+		}
+		return assignments, nil
+	}
+	return nil, fmt.Errorf("cpuset per replica %q must be %v or %v", mode, CpusetPerCore, CpusetPerNuma)
+}
+
+// Split cores into count contiguous groups, the first groups take the remainder
+func splitCores(cores []CPUCore, count int) ([][]CPUCore, error) {
+	if len(cores) < count {
+		return nil, fmt.Errorf("%v replicas need at least as many physical cores, the host has %v", count, len(cores))
+	}
+	var groups [][]CPUCore
+	start := 0
+	for group := 0; group < count; group++ {
+		size := len(cores) / count
+		if group < len(cores)%count {
+			size++
+		}
+		groups = append(groups, cores[start:start+size])
+		start += size
+	}
+	return groups, nil
+}
+
+func coreCPUList(cores []CPUCore) string {
+	var cpus []int
+	for _, core := range cores {
+		cpus = append(cpus, core.CPUs...)
+	}
+	return FormatCPUList(cpus)
+}
+
+// Pin the replicas of the containers to CPUs that don't overlap, the nth
+// replica of every replicated container shares the nth group of cores
+func (containerArray *Containers) SetCpusetPerReplica(topology CPUTopology, mode string) error {
+	replicas := 0
+	for _, cont := range containerArray.Containers {
+		replicas = max(replicas, cont.Replica)
+	}
+	if replicas == 0 {
+		return fmt.Errorf("cpuset per replica needs a container with Replicas set")
+	}
+	assignments, err := topology.Spread(replicas, mode)
+	if err != nil {
+		return err
+	}
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		if cont.Replica == 0 {
+			continue
+		}
+		assignment := assignments[cont.Replica-1]
+		cont.HostConfig.CpusetCpus = assignment.Cpus
+		if assignment.Mems != "" {
+			cont.HostConfig.CpusetMems = assignment.Mems
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Fake host with two sockets of four hyperthreaded cores, one NUMA node each
+func createFakeCPUTopology(t *testing.T) {
+	fakeSysRoot := CreateFakeSysfs(t)
+	for cpu := 0; cpu < 8; cpu++ {
+		AddFakeCPU(t, fakeSysRoot, cpu, cpu/4, cpu%4)
+		AddFakeCPU(t, fakeSysRoot, cpu+8, cpu/4, cpu%4)
+	}
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{
+		"devices/system/cpu/online":         "0-15\n",
+		"devices/system/node/node0/cpulist": "0-3,8-11\n",
+		"devices/system/node/node1/cpulist": "4-7,12-15\n",
+	})
+}
+
+// TestReadCPUTopology: test reading physical cores and NUMA nodes from sysfs
+func TestReadCPUTopology(t *testing.T) {
+	createFakeCPUTopology(t)
+	topology, err := ReadCPUTopology()
+	require.NoError(t, err)
+	require.Len(t, topology.Cores, 8)
+	require.Equal(t, CPUCore{Node: 0, Package: 0, Core: 0, CPUs: []int{0, 8}}, topology.Cores[0])
+	require.Equal(t, CPUCore{Node: 1, Package: 1, Core: 3, CPUs: []int{7, 15}}, topology.Cores[7])
+
+	// Hosts without NUMA nodes have every core on node 0
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeCPU(t, fakeSysRoot, 0, 0, 0)
+	AddFakeCPU(t, fakeSysRoot, 1, 0, 1)
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{"devices/system/cpu/online": "0-1\n"})
+	topology, err = ReadCPUTopology()
+	require.NoError(t, err)
+	require.Equal(t, []CPUCore{{CPUs: []int{0}}, {Core: 1, CPUs: []int{1}}}, topology.Cores)
+
+	CreateFakeSysfs(t)
+	_, err = ReadCPUTopology()
+	require.Error(t, err)
+}
+
+// TestCPUTopologySpread: test splitting cores between replicas
+func TestCPUTopologySpread(t *testing.T) {
+	createFakeCPUTopology(t)
+	topology, err := ReadCPUTopology()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		replicas            int
+		mode                string
+		expectedErr         bool
+		expectedAssignments []CPUAssignment
+	}{
+		{"valid core halves", 2, CpusetPerCore, false, []CPUAssignment{{Cpus: "0-3,8-11"}, {Cpus: "4-7,12-15"}}},
+		{"valid core remainder", 3, CpusetPerCore, false, []CPUAssignment{{Cpus: "0-2,8-10"}, {Cpus: "3-5,11-13"}, {Cpus: "6-7,14-15"}}},
+		{"valid numa one replica", 1, CpusetPerNuma, false, []CPUAssignment{{Cpus: "0-3,8-11", Mems: "0"}}},
+		{"valid numa per node", 4, CpusetPerNuma, false, []CPUAssignment{{Cpus: "0-1,8-9", Mems: "0"}, {Cpus: "2-3,10-11", Mems: "0"}, {Cpus: "4-5,12-13", Mems: "1"}, {Cpus: "6-7,14-15", Mems: "1"}}},
+		{"invalid more replicas than cores", 9, CpusetPerCore, true, nil},
+		{"invalid more replicas than node cores", 10, CpusetPerNuma, true, nil},
+		{"invalid mode", 2, "socket", true, nil},
+		{"invalid no replicas", 0, CpusetPerCore, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasError := false
+			assignments, err := topology.Spread(tt.replicas, tt.mode)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			require.Equal(t, tt.expectedAssignments, assignments)
+		})
+	}
+}
+
+// TestSetCpusetPerReplica: test pinning the replicas of a pipeline together
+func TestSetCpusetPerReplica(t *testing.T) {
+	createFakeCPUTopology(t)
+	topology, err := ReadCPUTopology()
+	require.NoError(t, err)
+
+	tmpContainers := CreateTestContainers("", "")
+	require.Error(t, tmpContainers.SetCpusetPerReplica(topology, CpusetPerCore))
+
+	tmpContainers.Containers[0].Replicas = 2
+	tmpContainers.Containers[1].Replicas = 2
+	require.NoError(t, tmpContainers.ExpandReplicas())
+	require.NoError(t, tmpContainers.SetCpusetPerReplica(topology, CpusetPerNuma))
+	var cpusets []string
+	for _, cont := range tmpContainers.Containers {
+		cpusets = append(cpusets, cont.Name+" "+cont.HostConfig.CpusetCpus+" "+cont.HostConfig.CpusetMems)
+	}
+	require.Equal(t, []string{"Client-1 0-3,8-11 0", "Client-2 4-7,12-15 1", "Server-1 0-3,8-11 0", "Server-2 4-7,12-15 1"}, cpusets)
+}
@@ -0,0 +1,271 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Root of the host device and sysfs trees, tests point these at fake trees
+var (
+	devRoot = "/dev"
+	sysRoot = "/sys"
+)
+
+// PCI vendor ID of Intel devices, the only GPUs the OpenVINO GPU plugin uses
+const intelVendorID = "0x8086"
+
+// Host groups that own GPU device nodes on common distributions
+var gpuGroups = []string{"render", "video"}
+
+// Major number of DRM devices, used to allow GPU nodes in the device cgroup
+const drmMajor = 226
+
+// Device node found on the host
+type DeviceNode struct {
+	// Path of the node as seen on the host and in the container
+	Path string
+	// Group owning the node
+	Gid uint32
+}
+
+// GPU found in /sys/class/drm
+type GpuDevice struct {
+	// OpenVINO GPU index, -1 when OpenVINO can't use the GPU
+	Index      int    `json:"index"`
+	Card       string `json:"card"`
+	RenderNode string `json:"renderNode"`
+	PCIAddress string `json:"pciAddress"`
+	VendorID   string `json:"vendorId"`
+	DeviceID   string `json:"deviceId"`
+	Driver     string `json:"driver"`
+	Integrated bool   `json:"integrated"`
+}
+
+// Discover the GPUs from /sys/class/drm and number them like OpenVINO does:
+// the integrated GPU is GPU.0 and discrete GPUs follow in PCI address order
+func DiscoverGpus() ([]GpuDevice, error) {
+	drmDir := filepath.Join(sysRoot, "class", "drm")
+	entries, err := os.ReadDir(drmDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to list GPU devices %v", err)
+	}
+
+	var gpus []GpuDevice
+	for _, entry := range entries {
+		// Skip connectors such as card0-HDMI-A-1
+		if !strings.HasPrefix(entry.Name(), "card") || strings.Contains(entry.Name(), "-") {
+			continue
+		}
+		deviceDir := filepath.Join(drmDir, entry.Name(), "device")
+		gpu := GpuDevice{
+			Index:    -1,
+			Card:     entry.Name(),
+			VendorID: readSysfsAttribute(filepath.Join(deviceDir, "vendor")),
+			DeviceID: readSysfsAttribute(filepath.Join(deviceDir, "device")),
+		}
+		uevent := readSysfsUevent(filepath.Join(deviceDir, "uevent"))
+		gpu.Driver = uevent["DRIVER"]
+		gpu.PCIAddress = uevent["PCI_SLOT_NAME"]
+		if gpu.Driver == "" {
+			if driverPath, err := os.Readlink(filepath.Join(deviceDir, "driver")); err == nil {
+				gpu.Driver = filepath.Base(driverPath)
+			}
+		}
+		// Integrated GPUs sit on the root PCI bus
+		gpu.Integrated = strings.HasPrefix(gpu.PCIAddress, "0000:00:")
+
+		drmEntries, _ := os.ReadDir(filepath.Join(deviceDir, "drm"))
+		for _, drmEntry := range drmEntries {
+			if strings.HasPrefix(drmEntry.Name(), "renderD") {
+				gpu.RenderNode = drmEntry.Name()
+			}
+		}
+		gpus = append(gpus, gpu)
+	}
+
+	sort.SliceStable(gpus, func(i, j int) bool {
+		if gpus[i].Integrated != gpus[j].Integrated {
+			return gpus[i].Integrated
+		}
+		return gpus[i].PCIAddress < gpus[j].PCIAddress
+	})
+	index := 0
+	for i, gpu := range gpus {
+		if gpu.VendorID == intelVendorID && gpu.RenderNode != "" {
+			gpus[i].Index = index
+			index++
+		}
+	}
+	return gpus, nil
+}
+
+// Find the GPU OpenVINO knows as GPU.index
+func FindGpu(index int) (GpuDevice, error) {
+	gpus, err := DiscoverGpus()
+	if err != nil {
+		return GpuDevice{}, err
+	}
+	available := 0
+	for _, gpu := range gpus {
+		if gpu.Index == index {
+			return gpu, nil
+		}
+		if gpu.Index >= 0 {
+			available++
+		}
+	}
+	return GpuDevice{}, fmt.Errorf("GPU.%d not found, the host has %d GPU(s) usable by OpenVINO, run \"profile-launcher devices\" to list them", index, available)
+}
+
+// NPU found in /sys/class/accel
+type NpuDevice struct {
+	// OpenVINO NPU index
+	Index      int    `json:"index"`
+	Node       string `json:"node"`
+	PCIAddress string `json:"pciAddress"`
+	VendorID   string `json:"vendorId"`
+	DeviceID   string `json:"deviceId"`
+	Driver     string `json:"driver"`
+	// Major number of the accel device class, used for the device cgroup rule
+	Major string `json:"-"`
+}
+
+// Discover the NPUs exposed through the kernel accel subsystem
+func DiscoverNpus() ([]NpuDevice, error) {
+	accelDir := filepath.Join(sysRoot, "class", "accel")
+	entries, err := os.ReadDir(accelDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to list NPU devices %v", err)
+	}
+
+	var npus []NpuDevice
+	for _, entry := range entries {
+		minor, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "accel"))
+		if !strings.HasPrefix(entry.Name(), "accel") || err != nil {
+			continue
+		}
+		deviceDir := filepath.Join(accelDir, entry.Name(), "device")
+		uevent := readSysfsUevent(filepath.Join(deviceDir, "uevent"))
+		major, _, _ := strings.Cut(readSysfsAttribute(filepath.Join(accelDir, entry.Name(), "dev")), ":")
+		npus = append(npus, NpuDevice{
+			Index:      minor,
+			Node:       entry.Name(),
+			PCIAddress: uevent["PCI_SLOT_NAME"],
+			VendorID:   readSysfsAttribute(filepath.Join(deviceDir, "vendor")),
+			DeviceID:   readSysfsAttribute(filepath.Join(deviceDir, "device")),
+			Driver:     uevent["DRIVER"],
+			Major:      major,
+		})
+	}
+
+	sort.Slice(npus, func(i, j int) bool { return npus[i].Index < npus[j].Index })
+	for i := range npus {
+		npus[i].Index = i
+	}
+	return npus, nil
+}
+
+// Read a single value sysfs attribute, empty when missing
+func readSysfsAttribute(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(contents))
+}
+
+// Read the KEY=VALUE pairs of a sysfs uevent file
+func readSysfsUevent(path string) map[string]string {
+	uevent := map[string]string{}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return uevent
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if key, value, found := strings.Cut(line, "="); found {
+			uevent[key] = value
+		}
+	}
+	return uevent
+}
+
+// List the GPU render and card nodes under /dev/dri
+func ListGpuNodes() ([]DeviceNode, error) {
+	entries, err := os.ReadDir(filepath.Join(devRoot, "dri"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to list GPU devices %v", err)
+	}
+
+	var nodes []DeviceNode
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "renderD") && !strings.HasPrefix(entry.Name(), "card") {
+			continue
+		}
+		node, err := statDeviceNode("/dev/dri/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Stat a device node given by its /dev path
+func statDeviceNode(path string) (DeviceNode, error) {
+	info, err := os.Stat(filepath.Join(devRoot, strings.TrimPrefix(path, "/dev/")))
+	if err != nil {
+		return DeviceNode{}, fmt.Errorf("Failed to access device %v: %v", path, err)
+	}
+	return DeviceNode{Path: path, Gid: deviceGid(info)}, nil
+}
+
+// Group IDs a non-root container user needs to open the given device nodes
+func deviceGroupIDs(nodes []DeviceNode) []string {
+	gids := map[string]bool{}
+	for _, node := range nodes {
+		if node.Gid != 0 {
+			gids[strconv.FormatUint(uint64(node.Gid), 10)] = true
+		}
+	}
+	// Group names resolve inside the container, so pass the host IDs
+	for _, groupName := range gpuGroups {
+		if group, err := user.LookupGroup(groupName); err == nil {
+			gids[group.Gid] = true
+		}
+	}
+
+	var groupIDs []string
+	for gid := range gids {
+		groupIDs = append(groupIDs, gid)
+	}
+	sort.Strings(groupIDs)
+	return groupIDs
+}
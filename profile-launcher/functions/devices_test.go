@@ -0,0 +1,134 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestListGpuNodes: test listing GPU nodes under /dev/dri
+func TestListGpuNodes(t *testing.T) {
+	tests := []struct {
+		name          string
+		nodes         []string
+		expectedPaths []string
+	}{
+		{"valid card and render nodes", []string{"dri/card0", "dri/renderD128", "dri/by-path/pci-0000:00:02.0-card"}, []string{"/dev/dri/card0", "/dev/dri/renderD128"}},
+		{"valid no dri directory", []string{"video0"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CreateFakeDevTree(t, tt.nodes...)
+			nodes, err := ListGpuNodes()
+			require.NoError(t, err)
+
+			var paths []string
+			for _, node := range nodes {
+				paths = append(paths, node.Path)
+				require.Equal(t, uint32(os.Getgid()), node.Gid)
+			}
+			require.Equal(t, tt.expectedPaths, paths)
+		})
+	}
+}
+
+// TestDeviceGroupIDs: test collecting the groups owning device nodes
+func TestDeviceGroupIDs(t *testing.T) {
+	oldGpuGroups := gpuGroups
+	gpuGroups = []string{"not-a-real-group"}
+	defer func() { gpuGroups = oldGpuGroups }()
+
+	tests := []struct {
+		name        string
+		nodes       []DeviceNode
+		expectedIDs []string
+	}{
+		{"valid deduplicated groups", []DeviceNode{{Path: "/dev/dri/card0", Gid: 44}, {Path: "/dev/dri/renderD128", Gid: 105}, {Path: "/dev/dri/renderD129", Gid: 105}}, []string{strconv.Itoa(105), strconv.Itoa(44)}},
+		{"valid root owned node", []DeviceNode{{Path: "/dev/dri/renderD128", Gid: 0}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedIDs, deviceGroupIDs(tt.nodes))
+		})
+	}
+}
+
+// TestDiscoverGpus: test discovering GPUs from a fake sysfs tree
+func TestDiscoverGpus(t *testing.T) {
+	fakeSysRoot := CreateFakeSysfs(t)
+	// The discrete GPU registered first so its render node number is lower
+	AddFakeGpu(t, fakeSysRoot, "card0", "renderD128", "0000:03:00.0", intelVendorID, "xe")
+	AddFakeGpu(t, fakeSysRoot, "card1", "renderD129", "0000:00:02.0", intelVendorID, "i915")
+	AddFakeGpu(t, fakeSysRoot, "card2", "renderD130", "0000:01:00.0", "0x10de", "nvidia")
+
+	gpus, err := DiscoverGpus()
+	require.NoError(t, err)
+	require.Equal(t, []GpuDevice{
+		{Index: 0, Card: "card1", RenderNode: "renderD129", PCIAddress: "0000:00:02.0", VendorID: intelVendorID, DeviceID: "0x46a6", Driver: "i915", Integrated: true},
+		{Index: -1, Card: "card2", RenderNode: "renderD130", PCIAddress: "0000:01:00.0", VendorID: "0x10de", DeviceID: "0x46a6", Driver: "nvidia"},
+		{Index: 1, Card: "card0", RenderNode: "renderD128", PCIAddress: "0000:03:00.0", VendorID: intelVendorID, DeviceID: "0x46a6", Driver: "xe"},
+	}, gpus)
+
+	tests := []struct {
+		name               string
+		index              int
+		expectedErr        bool
+		expectedRenderNode string
+	}{
+		{"valid integrated GPU.0", 0, false, "renderD129"},
+		{"valid discrete GPU.1", 1, false, "renderD128"},
+		{"invalid missing GPU.2", 2, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasError := false
+			gpu, err := FindGpu(tt.index)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			require.Equal(t, tt.expectedRenderNode, gpu.RenderNode)
+		})
+	}
+}
+
+// TestDiscoverGpusNoSysfs: test discovery on hosts without DRM devices
+func TestDiscoverGpusNoSysfs(t *testing.T) {
+	CreateFakeSysfs(t)
+	gpus, err := DiscoverGpus()
+	require.NoError(t, err)
+	require.Empty(t, gpus)
+}
+
+// TestDiscoverNpus: test discovering NPUs from a fake sysfs tree
+func TestDiscoverNpus(t *testing.T) {
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeNpu(t, fakeSysRoot, "accel1", "0000:00:0c.0")
+	AddFakeNpu(t, fakeSysRoot, "accel0", "0000:00:0b.0")
+
+	npus, err := DiscoverNpus()
+	require.NoError(t, err)
+	require.Equal(t, []NpuDevice{
+		{Index: 0, Node: "accel0", PCIAddress: "0000:00:0b.0", VendorID: intelVendorID, DeviceID: "0x7d1d", Driver: "intel_vpu", Major: "261"},
+		{Index: 1, Node: "accel1", PCIAddress: "0000:00:0c.0", VendorID: intelVendorID, DeviceID: "0x7d1d", Driver: "intel_vpu", Major: "261"},
+	}, npus)
+}
@@ -20,35 +20,158 @@ package functions
 import (
 	"context"
 	"fmt"
-	"path/filepath"
-	"strings"
+	"io"
+	"slices"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 // Create and start the Docker container
 func (containerArray *Containers) DockerStartContainer(ctx context.Context, cli *client.Client) error {
-	for _, cont := range containerArray.Containers {
-		fmt.Println("Starting Docker Container")
-		fmt.Printf("%+v\n", cont)
-
-		resp, err := cli.ContainerCreate(ctx, &container.Config{
-			Image:      cont.DockerImage,
-			Env:        cont.Envs,
-			Entrypoint: strings.Split(cont.Entrypoint, " "),
-		},
+	if err := containerArray.DockerCreateNetworks(ctx, cli); err != nil {
+		return err
+	}
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		fmt.Printf("Starting container %v from %v\n", cont.Name, cont.DockerImage)
+		if err := cont.DockerCreateVolumes(ctx, cli); err != nil {
+			return err
+		}
+		if err := containerArray.MountSecretFiles(cont); err != nil {
+			return err
+		}
+
+		resp, err := cli.ContainerCreate(ctx, cont.DockerConfig(),
 			&cont.HostConfig,
-			nil, nil, cont.Name)
+			cont.NetworkingConfig(), nil, cont.Name)
 		if err != nil {
 			return err
 		}
+		cont.ContainerID = resp.ID
 
 		if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
 			return err
 		}
+		if err := containerArray.RunHooks(ctx, DockerRuntime{Client: cli}, HookPostStart, cont); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pull the images of the containers that are not available locally
+func (containerArray *Containers) DockerPullImages(ctx context.Context, cli *client.Client) error {
+	pulled := map[string]bool{}
+	for _, cont := range containerArray.Containers {
+		if pulled[cont.DockerImage] {
+			continue
+		}
+		pulled[cont.DockerImage] = true
+		if _, _, err := cli.ImageInspectWithRaw(ctx, cont.DockerImage); err == nil {
+			continue
+		} else if !client.IsErrNotFound(err) {
+			return fmt.Errorf("Failed to inspect image %v: %v", cont.DockerImage, err)
+		}
+
+		fmt.Printf("Pulling image %v\n", cont.DockerImage)
+		progress, err := cli.ImagePull(ctx, cont.DockerImage, types.ImagePullOptions{})
+		if err != nil {
+			return fmt.Errorf("Failed to pull image %v: %v", cont.DockerImage, err)
+		}
+		_, err = io.Copy(io.Discard, progress)
+		progress.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to pull image %v: %v", cont.DockerImage, err)
+		}
+	}
+	return nil
+}
+
+// Docker config of the container
+func (cont *Container) DockerConfig() *container.Config {
+	return &container.Config{
+		Image:        cont.DockerImage,
+		Env:          cont.Envs,
+		Entrypoint:   []string(cont.Entrypoint),
+		Cmd:          []string(cont.Command),
+		WorkingDir:   cont.WorkingDir,
+		User:         cont.User,
+		Hostname:     cont.Hostname,
+		Labels:       cont.Labels,
+		StopSignal:   cont.StopSignal,
+		StopTimeout:  cont.StopTimeout,
+		Tty:          cont.Tty,
+		ExposedPorts: cont.ExposedPorts(),
+		Healthcheck:  cont.DockerHealthcheck(),
+	}
+}
+
+// Docker healthcheck of the container, nil keeps the one of the image.
+// Healthchecks are validated when the profile is loaded.
+func (cont *Container) DockerHealthcheck() *container.HealthConfig {
+	if cont.Healthcheck == nil {
+		return nil
+	}
+	healthcheck, _ := cont.Healthcheck.DockerHealthcheck()
+	return healthcheck
+}
+
+// Wait for a started container to stop and return its exit code
+func (cont *Container) DockerWaitContainer(ctx context.Context, cli *client.Client) (int64, error) {
+	if cont.ContainerID == "" {
+		return 0, fmt.Errorf("Container %v was not started", cont.Name)
+	}
+	statusCh, errCh := cli.ContainerWait(ctx, cont.ContainerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return 0, fmt.Errorf("Failed to wait for container %v: %w", cont.Name, err)
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, fmt.Errorf("Failed to wait for container %v: %v", cont.Name, status.Error.Message)
+		}
+		return status.StatusCode, nil
+	}
+}
+
+// Copy the stdout and stderr of a container to a writer
+func (cont *Container) DockerCopyLogs(ctx context.Context, cli *client.Client, writer io.Writer) error {
+	if cont.ContainerID == "" {
+		return fmt.Errorf("Container %v was not started", cont.Name)
+	}
+	logs, err := cli.ContainerLogs(ctx, cont.ContainerID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return fmt.Errorf("Failed to get logs of container %v: %v", cont.Name, err)
+	}
+	defer logs.Close()
+
+	// Logs of a TTY are a single raw stream, others are multiplexed
+	if cont.Tty {
+		_, err = io.Copy(writer, logs)
+	} else {
+		_, err = stdcopy.StdCopy(writer, writer, logs)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to save logs of container %v: %v", cont.Name, err)
+	}
+	return nil
+}
+
+// Remove the started containers so their names can be used again
+func (containerArray *Containers) DockerRemoveContainers(ctx context.Context, cli *client.Client) error {
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		if cont.ContainerID == "" {
+			continue
+		}
+		if err := cli.ContainerRemove(ctx, cont.ContainerID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("Failed to remove container %v: %v", cont.Name, err)
+		}
+		cont.ContainerID = ""
 	}
 	return nil
 }
@@ -70,32 +193,75 @@ func (containerArray *Containers) SetHostNetwork() {
 
 // Setup the device mount
 func (containerArray *Containers) SetHostDevice(device string) {
+	for contIndex, _ := range containerArray.Containers {
+		containerArray.Containers[contIndex].AddHostDevice(device)
+	}
+}
+
+// Add supplementary groups to the container user
+func (containerArray *Containers) SetGroupAdd(groups ...string) {
+	for contIndex, _ := range containerArray.Containers {
+		containerArray.Containers[contIndex].AddGroups(groups...)
+	}
+}
+
+// Allow devices matching the rule in the container device cgroup
+func (containerArray *Containers) SetDeviceCgroupRule(rule string) {
+	for contIndex, _ := range containerArray.Containers {
+		containerArray.Containers[contIndex].AddDeviceCgroupRule(rule)
+	}
+}
+
+// Setup the device mount for a single container
+func (cont *Container) AddHostDevice(device string) {
 	deviceMount := container.DeviceMapping{
 		PathOnHost:        device,
 		PathInContainer:   device,
 		CgroupPermissions: "rwm",
 	}
+	cont.HostConfig.Devices = append(cont.HostConfig.Devices, deviceMount)
+}
 
-	for contIndex, _ := range containerArray.Containers {
-		containerArray.Containers[contIndex].HostConfig.Devices = append(containerArray.Containers[contIndex].HostConfig.Devices, deviceMount)
+// Add supplementary groups to the user of a single container
+func (cont *Container) AddGroups(groups ...string) {
+	for _, group := range groups {
+		if !slices.Contains(cont.HostConfig.GroupAdd, group) {
+			cont.HostConfig.GroupAdd = append(cont.HostConfig.GroupAdd, group)
+		}
 	}
 }
 
-func CreateVolumeMount(vol string) (mount.Mount, error) {
-	volSplit := strings.Split(vol, ":")
-	if len(volSplit) < 2 {
-		return mount.Mount{}, fmt.Errorf("Volume format incorrect, Ensure format is (target):(destination)")
+// Allow devices matching the rule in the device cgroup of a single container
+func (cont *Container) AddDeviceCgroupRule(rule string) {
+	if !slices.Contains(cont.HostConfig.DeviceCgroupRules, rule) {
+		cont.HostConfig.DeviceCgroupRules = append(cont.HostConfig.DeviceCgroupRules, rule)
 	}
+}
 
-	sourcePath, err := filepath.Abs(volSplit[0])
+// Docker mount of a volume in the short form source:target[:options]
+func CreateVolumeMount(vol string) (mount.Mount, error) {
+	spec, err := ParseVolume(vol)
 	if err != nil {
-		return mount.Mount{}, fmt.Errorf("Failed to get volume path %v", err)
+		return mount.Mount{}, err
 	}
+	return spec.Mount()
+}
 
-	return mount.Mount{
-		Type:     mount.TypeBind,
-		Source:   sourcePath,
-		Target:   volSplit[1],
-		ReadOnly: false,
-	}, nil
+// Create the named volumes the container mounts that don't exist yet
+func (cont *Container) DockerCreateVolumes(ctx context.Context, cli *client.Client) error {
+	for _, volMount := range cont.HostConfig.Mounts {
+		if volMount.Type != mount.TypeVolume || volMount.Source == "" {
+			continue
+		}
+		if _, err := cli.VolumeInspect(ctx, volMount.Source); err == nil {
+			continue
+		} else if !client.IsErrNotFound(err) {
+			return fmt.Errorf("Failed to inspect volume %v: %v", volMount.Source, err)
+		}
+		fmt.Printf("Creating volume %v\n", volMount.Source)
+		if _, err := cli.VolumeCreate(ctx, volume.CreateOptions{Name: volMount.Source}); err != nil {
+			return fmt.Errorf("Failed to create volume %v: %v", volMount.Source, err)
+		}
+	}
+	return nil
 }
@@ -119,6 +119,8 @@ func TestCreateVolumeMount(t *testing.T) {
 		expectedVolume mount.Mount
 	}{
 		{"valid volume", false, "./test-profile/valid-profile:/test", mount.Mount{Type: mount.TypeBind, Source: sourcePath, Target: "/test", ReadOnly: false}},
+		{"valid read only volume", false, "./test-profile/valid-profile:/test:ro", mount.Mount{Type: mount.TypeBind, Source: sourcePath, Target: "/test", ReadOnly: true}},
+		{"valid named volume", false, "models:/models:ro,nocopy", mount.Mount{Type: mount.TypeVolume, Source: "models", Target: "/models", ReadOnly: true, VolumeOptions: &mount.VolumeOptions{NoCopy: true}}},
 		{"invalid volume format", true, "./test-profile/valid-profile", mount.Mount{}},
 	}
 	for _, tt := range tests {
@@ -134,3 +136,34 @@ func TestCreateVolumeMount(t *testing.T) {
 		})
 	}
 }
+
+// TestDockerConfig: test the Docker config built from the container fields
+func TestDockerConfig(t *testing.T) {
+	stopTimeout := 30
+	cont := CreateTestContainers("", "").Containers[0]
+	cont.Envs = []string{"TEST_ENV=aaa"}
+	cont.Command = CommandArgs{"--model", "yolov5s"}
+	cont.WorkingDir = "/app"
+	cont.User = "1000:1000"
+	cont.Hostname = "client"
+	cont.Labels = map[string]string{"app": "demo"}
+	cont.StopSignal = "SIGINT"
+	cont.StopTimeout = &stopTimeout
+	cont.Tty = true
+
+	config := cont.DockerConfig()
+	require.Equal(t, "test:dev", config.Image)
+	require.Equal(t, []string{"TEST_ENV=aaa"}, config.Env)
+	require.Equal(t, []string{"/script/entrypoint.sh"}, []string(config.Entrypoint))
+	require.Equal(t, []string{"--model", "yolov5s"}, []string(config.Cmd))
+	require.Equal(t, "/app", config.WorkingDir)
+	require.Equal(t, "1000:1000", config.User)
+	require.Equal(t, "client", config.Hostname)
+	require.Equal(t, map[string]string{"app": "demo"}, config.Labels)
+	require.Equal(t, "SIGINT", config.StopSignal)
+	require.Equal(t, 30, *config.StopTimeout)
+	require.True(t, config.Tty)
+
+	// Without a Command the image CMD is kept
+	require.Nil(t, CreateTestContainers("", "").Containers[0].DockerConfig().Cmd)
+}
@@ -0,0 +1,135 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Env of a container by key, in the order the keys were first set
+type EnvMap struct {
+	keys    []string
+	entries map[string]string
+}
+
+// Env map of KEY=VALUE entries, a later entry replaces an earlier one
+func NewEnvMap(envs []string) *EnvMap {
+	envMap := &EnvMap{entries: map[string]string{}}
+	for _, env := range envs {
+		key, _, _ := strings.Cut(env, "=")
+		envMap.setEntry(key, env)
+	}
+	return envMap
+}
+
+func (envMap *EnvMap) setEntry(key string, entry string) {
+	if _, ok := envMap.entries[key]; !ok {
+		envMap.keys = append(envMap.keys, key)
+	}
+	envMap.entries[key] = entry
+}
+
+// Set the value of a key, keeping its position when it is already set
+func (envMap *EnvMap) Set(key string, value string) {
+	envMap.setEntry(key, key+"="+value)
+}
+
+// Remove a key
+func (envMap *EnvMap) Unset(key string) {
+	if _, ok := envMap.entries[key]; !ok {
+		return
+	}
+	delete(envMap.entries, key)
+	for index, setKey := range envMap.keys {
+		if setKey == key {
+			envMap.keys = append(envMap.keys[:index], envMap.keys[index+1:]...)
+			break
+		}
+	}
+}
+
+// Value of a key, false when it isn't set
+func (envMap *EnvMap) Get(key string) (string, bool) {
+	entry, ok := envMap.entries[key]
+	if !ok {
+		return "", false
+	}
+	_, value, _ := strings.Cut(entry, "=")
+	return value, true
+}
+
+// KEY=VALUE entries in order
+func (envMap *EnvMap) Envs() []string {
+	var envs []string
+	for _, key := range envMap.keys {
+		envs = append(envs, envMap.entries[key])
+	}
+	return envs
+}
+
+// Entries of an env file. Blank lines and # comments are skipped and a
+// line with only a KEY takes its value from the host env like docker
+// --env-file does, it is skipped when the host doesn't set it.
+func ParseEnvFile(contents string) []string {
+	var envs []string
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.Contains(line, "=") {
+			value, ok := os.LookupEnv(trimmed)
+			if !ok {
+				continue
+			}
+			line = trimmed + "=" + value
+		}
+		envs = append(envs, line)
+	}
+	return envs
+}
+
+// Parse a -e value: KEY=VALUE sets the key, the value may contain "=", a
+// lone KEY takes its value from the host env and !KEY unsets it
+func ParseEnvOverride(override string) (key string, value string, unset bool, err error) {
+	if removed, found := strings.CutPrefix(override, "!"); found {
+		if !validEnvKey(removed) {
+			return "", "", false, fmt.Errorf("env %v format incorrect, ensure env to unset is EnvName.", override)
+		}
+		return removed, "", true, nil
+	}
+	key, value, found := strings.Cut(override, "=")
+	if !validEnvKey(key) {
+		return "", "", false, fmt.Errorf("env %v format incorrect, ensure env is EnvName=Value or EnvName.", override)
+	}
+	if !found {
+		hostValue, ok := os.LookupEnv(key)
+		if !ok {
+			return "", "", false, fmt.Errorf("env %v is not set in the host environment, ensure env is EnvName=Value.", key)
+		}
+		value = hostValue
+	}
+	return key, value, false, nil
+}
+
+func validEnvKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " \t\n=")
+}
@@ -0,0 +1,83 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnvMap: test setting and removing env keys in order
+func TestEnvMap(t *testing.T) {
+	envMap := NewEnvMap([]string{"A=1", "B=2", "A=3", "C"})
+	require.Equal(t, []string{"A=3", "B=2", "C"}, envMap.Envs())
+
+	envMap.Set("B", "x=y")
+	envMap.Set("D", "")
+	envMap.Unset("A")
+	envMap.Unset("MISSING")
+	require.Equal(t, []string{"B=x=y", "C", "D="}, envMap.Envs())
+
+	value, ok := envMap.Get("B")
+	require.True(t, ok)
+	require.Equal(t, "x=y", value)
+	_, ok = envMap.Get("A")
+	require.False(t, ok)
+}
+
+// TestParseEnvFile: test reading env file entries
+func TestParseEnvFile(t *testing.T) {
+	t.Setenv("HOST_ENV", "host")
+	contents := "# comment\nTEST_ENV=123\r\n\n  \nURL=rtsp://host/a?b=c\n  # indented comment\nHOST_ENV\nMISSING_HOST_ENV\n"
+	require.Equal(t, []string{"TEST_ENV=123", "URL=rtsp://host/a?b=c", "HOST_ENV=host"}, ParseEnvFile(contents))
+}
+
+// TestParseEnvOverride: test the forms of -e values
+func TestParseEnvOverride(t *testing.T) {
+	t.Setenv("HOST_ENV", "host")
+	tests := []struct {
+		name          string
+		override      string
+		expectedErr   bool
+		expectedKey   string
+		expectedValue string
+		expectedUnset bool
+	}{
+		{"valid value", "BATCH=4", false, "BATCH", "4", false},
+		{"valid value with equals", "ARGS=--a=b", false, "ARGS", "--a=b", false},
+		{"valid empty value", "BATCH=", false, "BATCH", "", false},
+		{"valid host value", "HOST_ENV", false, "HOST_ENV", "host", false},
+		{"valid unset", "!BATCH", false, "BATCH", "", true},
+		{"invalid host value missing", "MISSING_HOST_ENV", true, "", "", false},
+		{"invalid empty name", "=4", true, "", "", false},
+		{"invalid unset without name", "!", true, "", "", false},
+		{"invalid name with space", "MY BATCH=4", true, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, value, unset, err := ParseEnvOverride(tt.override)
+			require.Equal(t, tt.expectedErr, err != nil)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedKey, key)
+				require.Equal(t, tt.expectedValue, value)
+				require.Equal(t, tt.expectedUnset, unset)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Name of the profile config file in a profile directory
+const ProfileConfigFile = "profile_config.yaml"
+
+// Profile keys pulling in other profile configs
+type profileInheritance struct {
+	Extends string   `yaml:"Extends"`
+	Include []string `yaml:"Include"`
+}
+
+// Generic form of a profile config, merged before it is decoded into Containers
+type profileMap = map[string]interface{}
+
+// Load a profile config resolving Extends and Include: the extended profile
+// comes first, then the included files in order, then the config itself.
+// Env and secret files of other directories are made absolute so they are
+// found from the profile directory.
+func loadProfileMap(configPath string, rootDir string, chain []string) (profileMap, error) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get config path %v", err)
+	}
+	for _, parent := range chain {
+		if parent == absPath {
+			return nil, fmt.Errorf("Profile config inheritance cycle: %v -> %v", strings.Join(chain, " -> "), absPath)
+		}
+	}
+	chain = append(chain, absPath)
+
+	contents, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config file: %v, error: %v", configPath, err)
+	}
+	inheritance := profileInheritance{}
+	if err := yaml.Unmarshal(contents, &inheritance); err != nil {
+		return nil, fmt.Errorf("%v: %v", configPath, err)
+	}
+	profile := profileMap{}
+	if err := yaml.Unmarshal(contents, &profile); err != nil {
+		return nil, fmt.Errorf("%v: %v", configPath, err)
+	}
+	delete(profile, "Extends")
+	delete(profile, "Include")
+
+	configDir := filepath.Dir(absPath)
+	if configDir != rootDir {
+		if err := absProfilePaths(profile, configDir); err != nil {
+			return nil, fmt.Errorf("%v: %v", configPath, err)
+		}
+	}
+
+	merged := profileMap{}
+	if inheritance.Extends != "" {
+		basePath := filepath.Join(configDir, inheritance.Extends, ProfileConfigFile)
+		if merged, err = loadProfileMap(basePath, rootDir, chain); err != nil {
+			return nil, err
+		}
+	}
+	for _, include := range inheritance.Include {
+		included, err := loadProfileMap(filepath.Join(configDir, include), rootDir, chain)
+		if err != nil {
+			return nil, err
+		}
+		if merged, err = mergeProfile(merged, included); err != nil {
+			return nil, fmt.Errorf("%v: %v", include, err)
+		}
+	}
+	if merged, err = mergeProfile(merged, profile); err != nil {
+		return nil, fmt.Errorf("%v: %v", configPath, err)
+	}
+	return merged, nil
+}
+
+// Make the env files, secret files, secret store and hook directories of a
+// config from another directory absolute
+func absProfilePaths(profile profileMap, configDir string) error {
+	absPath := func(mapping profileMap, key string) {
+		if filePath, ok := mapping[key].(string); ok && filePath != "" && !filepath.IsAbs(filePath) {
+			mapping[key] = filepath.Join(configDir, filePath)
+		}
+	}
+	containers, _ := profile["Containers"].([]interface{})
+	for _, item := range containers {
+		cont, ok := item.(profileMap)
+		if !ok {
+			return fmt.Errorf("Containers entries must be mappings")
+		}
+		absPath(cont, "EnvironmentVariableFiles")
+	}
+	secrets, _ := profile["Secrets"].([]interface{})
+	for _, item := range secrets {
+		if secret, ok := item.(profileMap); ok {
+			absPath(secret, "File")
+		}
+	}
+	if store, ok := profile["SecretStore"].(profileMap); ok {
+		absPath(store, "File")
+		absPath(store, "KeyFile")
+	}
+	// Host hooks run from the directory of the config that declares them
+	hooks, _ := profile["Hooks"].(profileMap)
+	for _, stage := range hooks {
+		stageHooks, _ := stage.([]interface{})
+		for _, item := range stageHooks {
+			hook, ok := item.(profileMap)
+			if !ok {
+				return fmt.Errorf("Hooks entries must be mappings")
+			}
+			if dir, _ := hook["Dir"].(string); dir == "" {
+				hook["Dir"] = configDir
+			}
+			absPath(hook, "Dir")
+		}
+	}
+	return nil
+}
+
+// Merge a profile config over another: containers and secrets merge by
+// Name, a null value removes the inherited key and other values replace it
+func mergeProfile(base profileMap, overlay profileMap) (profileMap, error) {
+	return mergeMaps(base, overlay, func(key string, baseValue interface{}, value interface{}) (interface{}, error) {
+		switch key {
+		case "Containers":
+			return mergeContainers(baseValue, value)
+		case "Secrets":
+			return mergeKeyedList(key, baseValue, value, entryName)
+		}
+		return mergeValues(baseValue, value), nil
+	})
+}
+
+// Name of a list entry such as a secret, "!NAME" removes an inherited one
+func entryName(entry interface{}) string {
+	if mapping, ok := entry.(profileMap); ok {
+		return fmt.Sprint(mapping["Name"])
+	}
+	return fmt.Sprint(entry)
+}
+
+// Merge two mappings key by key, a null overlay value removes the key
+func mergeMaps(base profileMap, overlay profileMap, mergeKey func(key string, baseValue interface{}, value interface{}) (interface{}, error)) (profileMap, error) {
+	merged := profileMap{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		mergedValue, err := mergeKey(key, merged[key], value)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = mergedValue
+	}
+	return merged, nil
+}
+
+// Deep merge mappings such as HostConfig, any other value replaces the base
+func mergeValues(base interface{}, overlay interface{}) interface{} {
+	baseMap, baseIsMap := base.(profileMap)
+	overlayMap, overlayIsMap := overlay.(profileMap)
+	if !baseIsMap || !overlayIsMap {
+		return overlay
+	}
+	merged, _ := mergeMaps(baseMap, overlayMap, func(key string, baseValue interface{}, value interface{}) (interface{}, error) {
+		return mergeValues(baseValue, value), nil
+	})
+	return merged
+}
+
+// Merge containers by Name, "Remove: true" drops an inherited container
+func mergeContainers(base interface{}, overlay interface{}) (interface{}, error) {
+	baseList, _ := base.([]interface{})
+	overlayList, ok := overlay.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Containers must be a list")
+	}
+
+	merged := append([]interface{}(nil), baseList...)
+	for _, item := range overlayList {
+		cont, ok := item.(profileMap)
+		if !ok {
+			return nil, fmt.Errorf("Containers entries must be mappings")
+		}
+		name, _ := cont["Name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("Containers entries need a Name to be merged")
+		}
+		index := -1
+		for baseIndex, baseItem := range merged {
+			if baseCont, ok := baseItem.(profileMap); ok && baseCont["Name"] == name {
+				index = baseIndex
+			}
+		}
+
+		remove, _ := cont["Remove"].(bool)
+		delete(cont, "Remove")
+		if remove {
+			if index < 0 {
+				return nil, fmt.Errorf("Container %v can't be removed, it is not inherited", name)
+			}
+			merged = append(merged[:index], merged[index+1:]...)
+			continue
+		}
+		baseCont := profileMap{}
+		if index >= 0 {
+			baseCont = merged[index].(profileMap)
+		}
+		mergedCont, err := mergeMaps(baseCont, cont, mergeContainerKey)
+		if err != nil {
+			return nil, fmt.Errorf("Container %v: %v", name, err)
+		}
+		if index < 0 {
+			merged = append(merged, mergedCont)
+		} else {
+			merged[index] = mergedCont
+		}
+	}
+	return merged, nil
+}
+
+// Envs and Secrets merge by name and Volumes by container path, "!NAME"
+// and "!/path" remove inherited entries
+func mergeContainerKey(key string, base interface{}, overlay interface{}) (interface{}, error) {
+	switch key {
+	case "Secrets":
+		return mergeKeyedList(key, base, overlay, entryName)
+	case "Envs":
+		return mergeKeyedList(key, base, overlay, func(env interface{}) string {
+			name, _, _ := strings.Cut(fmt.Sprint(env), "=")
+			return name
+		})
+	case "Volumes":
+		return mergeKeyedList(key, base, overlay, func(volume interface{}) string {
+			if longVolume, ok := volume.(profileMap); ok {
+				return fmt.Sprint(longVolume["Target"])
+			}
+			spec, err := ParseVolume(fmt.Sprint(volume))
+			if err != nil {
+				return fmt.Sprint(volume)
+			}
+			return spec.Target
+		})
+	}
+	return mergeValues(base, overlay), nil
+}
+
+// Mappings in the list are kept as they are, other entries become strings
+func mergeKeyedList(key string, base interface{}, overlay interface{}, entryKey func(interface{}) string) (interface{}, error) {
+	baseList, _ := base.([]interface{})
+	overlayList, ok := overlay.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v must be a list", key)
+	}
+
+	listEntry := func(item interface{}) interface{} {
+		if _, ok := item.(profileMap); ok {
+			return item
+		}
+		return fmt.Sprint(item)
+	}
+	var merged []interface{}
+	for _, item := range baseList {
+		merged = append(merged, listEntry(item))
+	}
+	for _, item := range overlayList {
+		entry := listEntry(item)
+		entryString, _ := entry.(string)
+		removed, remove := strings.CutPrefix(entryString, "!")
+		replaced := false
+		for index := 0; index < len(merged); index++ {
+			baseKey := entryKey(merged[index])
+			if remove && baseKey == removed {
+				merged = append(merged[:index], merged[index+1:]...)
+				index--
+			} else if !remove && baseKey == entryKey(entry) {
+				merged[index] = entry
+				replaced = true
+			}
+		}
+		if !remove && !replaced {
+			merged = append(merged, entry)
+		}
+	}
+	if merged == nil {
+		merged = []interface{}{}
+	}
+	return merged, nil
+}
+
+// Profile config of configDir with Extends and Include resolved
+func RenderYamlConfig(configDir string) ([]byte, error) {
+	rootDir, err := filepath.Abs(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get config path %v", err)
+	}
+	profile, err := loadProfileMap(filepath.Join(configDir, ProfileConfigFile), rootDir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var node yaml.Node
+	if err := node.Encode(profile); err != nil {
+		return nil, err
+	}
+	nameFirst(&node)
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Move the Name key first in mappings, maps otherwise encode in key order
+func nameFirst(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for index := 2; index < len(node.Content); index += 2 {
+			if node.Content[index].Value == "Name" {
+				pair := []*yaml.Node{node.Content[index], node.Content[index+1]}
+				copy(node.Content[2:index+2], node.Content[:index])
+				copy(node.Content[:2], pair)
+			}
+		}
+	}
+	for _, child := range node.Content {
+		nameFirst(child)
+	}
+}
@@ -0,0 +1,217 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+)
+
+const baseProfileYaml = `Containers:
+  - Name: Client
+    DockerImage: test:dev
+    EnvironmentVariableFiles: profile.env
+    Envs:
+      - MODEL=yolov5s
+      - BATCH_SIZE=1
+    Volumes:
+      - ./results:/tmp/results
+      - ./models:/models
+    HostConfig:
+      ipcmode: host
+      shmsize: 1024
+  - Name: Server
+    DockerImage: server:dev
+    EnvironmentVariableFiles: profile.env
+`
+
+// Write profile directories given as directory -> file -> contents
+func writeTestProfiles(t *testing.T, profiles map[string]map[string]string) string {
+	root := t.TempDir()
+	for dir, files := range profiles {
+		for name, contents := range files {
+			filePath := filepath.Join(root, dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+			require.NoError(t, os.WriteFile(filePath, []byte(contents), 0644))
+		}
+	}
+	return root
+}
+
+// TestGetYamlConfigExtends: test merging a profile over the profile it extends
+func TestGetYamlConfigExtends(t *testing.T) {
+	root := writeTestProfiles(t, map[string]map[string]string{
+		"base": {ProfileConfigFile: baseProfileYaml, "profile.env": "TEST_ENV=base"},
+		"gpu": {
+			ProfileConfigFile: `Extends: ../base
+Include:
+  - gpu.yaml
+Containers:
+  - Name: Client
+    Envs:
+      - MODEL=yolov8
+      - "!BATCH_SIZE"
+    Volumes:
+      - "!/models"
+      - Source: ./other-results
+        Target: /tmp/results
+        ReadOnly: true
+    HostConfig:
+      privileged: true
+      shmsize: null
+  - Name: Server
+    Remove: true
+  - Name: Extra
+    DockerImage: extra:dev
+    EnvironmentVariableFiles: extra.env
+`,
+			"gpu.yaml":  "TargetDevice: GPU\nContainers:\n  - Name: Client\n    TargetDevice: GPU.0\n",
+			"extra.env": "EXTRA=1",
+		},
+	})
+	configDir := filepath.Join(root, "gpu")
+
+	containers, err := GetYamlConfig(configDir)
+	require.NoError(t, err)
+	require.Equal(t, "GPU", containers.TargetDevice)
+	require.Len(t, containers.Containers, 2)
+
+	client := containers.Containers[0]
+	require.Equal(t, "Client", client.Name)
+	require.Equal(t, "test:dev", client.DockerImage)
+	require.Equal(t, "GPU.0", client.TargetDevice)
+	require.Equal(t, filepath.Join(root, "base", "profile.env"), client.EnvironmentVariableFiles)
+	require.Equal(t, []string{"MODEL=yolov8"}, client.Envs)
+	require.Equal(t, []VolumeSpec{{Type: mount.TypeBind, Source: "./other-results", Target: "/tmp/results", ReadOnly: true}}, client.Volumes)
+	require.Equal(t, container.IpcMode("host"), client.HostConfig.IpcMode)
+	require.True(t, client.HostConfig.Privileged)
+	require.Zero(t, client.HostConfig.ShmSize)
+
+	extra := containers.Containers[1]
+	require.Equal(t, "Extra", extra.Name)
+	require.Equal(t, "extra.env", extra.EnvironmentVariableFiles)
+
+	// Inherited env files are found from the profile directory
+	require.NoError(t, containers.GetEnv(configDir))
+	require.Equal(t, []string{"TEST_ENV=base", "MODEL=yolov8"}, containers.Containers[0].Envs)
+	require.Equal(t, []string{"EXTRA=1"}, containers.Containers[1].Envs)
+}
+
+// TestGetYamlConfigExtendsHooks: test inherited host hooks running from the profile declaring them
+func TestGetYamlConfigExtendsHooks(t *testing.T) {
+	root := writeTestProfiles(t, map[string]map[string]string{
+		"base": {
+			ProfileConfigFile: `Hooks:
+  PrePull:
+    - Name: setup
+      Command: ["./setup.sh"]
+    - Name: scripts
+      Command: ["sh", "-c", "pwd"]
+      Dir: scripts
+`,
+			"setup.sh":      "#!/bin/sh\npwd\n",
+			"scripts/.keep": "",
+		},
+		"gpu": {
+			ProfileConfigFile: `Extends: ../base
+Hooks:
+  PostReady:
+    - Name: ready
+      Command: ["sh", "-c", "pwd"]
+`,
+		},
+	})
+	require.NoError(t, os.Chmod(filepath.Join(root, "base", "setup.sh"), 0755))
+	configDir := filepath.Join(root, "gpu")
+
+	containers, err := GetYamlConfig(configDir)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "base"), containers.Hooks.PrePull[0].Dir)
+	require.Equal(t, filepath.Join(root, "base", "scripts"), containers.Hooks.PrePull[1].Dir)
+	require.Empty(t, containers.Hooks.PostReady[0].Dir)
+
+	require.NoError(t, containers.SetHooks(configDir))
+	containers.HookContext.LogDir = t.TempDir()
+	require.NoError(t, containers.RunHooks(context.Background(), &fakeHookRuntime{}, HookPrePull, nil))
+	require.NoError(t, containers.RunHooks(context.Background(), &fakeHookRuntime{}, HookPostReady, nil))
+
+	hookLog, err := os.ReadFile(filepath.Join(containers.HookContext.LogDir, HookLogFile))
+	require.NoError(t, err)
+	require.Contains(t, string(hookLog), "=== PrePull hook setup\n"+filepath.Join(root, "base")+"\n")
+	require.Contains(t, string(hookLog), "=== PrePull hook scripts\n"+filepath.Join(root, "base", "scripts")+"\n")
+	require.Contains(t, string(hookLog), "=== PostReady hook ready\n"+configDir+"\n")
+}
+
+// TestGetYamlConfigExtendsErrors: test invalid inheritance
+func TestGetYamlConfigExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]map[string]string
+	}{
+		{"invalid extends cycle", map[string]map[string]string{
+			"a": {ProfileConfigFile: "Extends: ../b\n"},
+			"b": {ProfileConfigFile: "Extends: ../a\n"},
+		}},
+		{"invalid include cycle", map[string]map[string]string{
+			"a": {ProfileConfigFile: "Include: [common.yaml]\n", "common.yaml": "Include: [common.yaml]\n"},
+		}},
+		{"invalid missing base", map[string]map[string]string{
+			"a": {ProfileConfigFile: "Extends: ../missing\n"},
+		}},
+		{"invalid remove not inherited", map[string]map[string]string{
+			"base": {ProfileConfigFile: baseProfileYaml},
+			"a":    {ProfileConfigFile: "Extends: ../base\nContainers:\n  - Name: Other\n    Remove: true\n"},
+		}},
+		{"invalid container without name", map[string]map[string]string{
+			"base": {ProfileConfigFile: baseProfileYaml},
+			"a":    {ProfileConfigFile: "Extends: ../base\nContainers:\n  - DockerImage: test:dev\n"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTestProfiles(t, tt.profiles)
+			_, err := GetYamlConfig(filepath.Join(root, "a"))
+			require.Error(t, err)
+		})
+	}
+}
+
+// TestRenderYamlConfig: test printing the flattened profile
+func TestRenderYamlConfig(t *testing.T) {
+	root := writeTestProfiles(t, map[string]map[string]string{
+		"base": {ProfileConfigFile: baseProfileYaml},
+		"a":    {ProfileConfigFile: "Extends: ../base\nContainers:\n  - Name: Server\n    Remove: true\n"},
+	})
+
+	rendered, err := RenderYamlConfig(filepath.Join(root, "a"))
+	require.NoError(t, err)
+	require.NotContains(t, string(rendered), "Extends")
+	require.NotContains(t, string(rendered), "Server")
+	require.Contains(t, string(rendered), "EnvironmentVariableFiles: "+filepath.Join(root, "base", "profile.env"))
+
+	// A profile without inheritance renders as it is
+	rendered, err = RenderYamlConfig(testConfigDir)
+	require.NoError(t, err)
+	require.Contains(t, string(rendered), "EnvironmentVariableFiles: profile.env")
+}
@@ -0,0 +1,300 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"gopkg.in/yaml.v3"
+)
+
+// Health of a container once Docker has run its healthcheck
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Healthcheck of a container: Test runs inside the container like a Docker
+// HEALTHCHECK, HTTP, TCP and Log are probed from the host for images that
+// have no healthcheck
+type Healthcheck struct {
+	Test        HealthcheckTest `yaml:"Test,omitempty" json:"test,omitempty"`
+	Interval    string          `yaml:"Interval,omitempty" json:"interval,omitempty"`
+	Timeout     string          `yaml:"Timeout,omitempty" json:"timeout,omitempty"`
+	Retries     int             `yaml:"Retries,omitempty" json:"retries,omitempty"`
+	StartPeriod string          `yaml:"StartPeriod,omitempty" json:"startPeriod,omitempty"`
+	// URL answering a GET with a 2xx or 3xx status once ready
+	HTTP string `yaml:"HTTP,omitempty" json:"http,omitempty"`
+	// host:port accepting connections once ready
+	TCP string `yaml:"TCP,omitempty" json:"tcp,omitempty"`
+	// Regular expression matching a line of the container logs once ready
+	Log string `yaml:"Log,omitempty" json:"log,omitempty"`
+}
+
+// Docker healthcheck test. A string runs with the container shell, a list
+// runs as is and NONE disables the healthcheck of the image.
+type HealthcheckTest []string
+
+func (test *HealthcheckTest) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Value {
+		case "":
+			*test = nil
+		case "NONE":
+			*test = HealthcheckTest{"NONE"}
+		default:
+			*test = HealthcheckTest{"CMD-SHELL", node.Value}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		if len(list) > 0 && list[0] != "CMD" && list[0] != "CMD-SHELL" && list[0] != "NONE" {
+			list = append([]string{"CMD"}, list...)
+		}
+		*test = list
+		return nil
+	}
+	return fmt.Errorf("line %d: healthcheck test must be a string or a list of strings", node.Line)
+}
+
+// Healthchecks are validated when the profile is loaded
+func (healthcheck *Healthcheck) UnmarshalYAML(value *yaml.Node) error {
+	type plainHealthcheck Healthcheck
+	if err := value.Decode((*plainHealthcheck)(healthcheck)); err != nil {
+		return err
+	}
+	if err := healthcheck.validate(); err != nil {
+		return fmt.Errorf("line %v: %v", value.Line, err)
+	}
+	return nil
+}
+
+func (healthcheck Healthcheck) validate() error {
+	if _, err := healthcheck.DockerHealthcheck(); err != nil {
+		return err
+	}
+	if healthcheck.HTTP != "" {
+		probeURL, err := url.Parse(healthcheck.HTTP)
+		if err != nil || (probeURL.Scheme != "http" && probeURL.Scheme != "https") || probeURL.Host == "" {
+			return fmt.Errorf("healthcheck HTTP %q must be an http or https URL", healthcheck.HTTP)
+		}
+	}
+	if healthcheck.TCP != "" {
+		if _, _, err := net.SplitHostPort(healthcheck.TCP); err != nil {
+			return fmt.Errorf("healthcheck TCP %q must be host:port", healthcheck.TCP)
+		}
+	}
+	if healthcheck.Log != "" {
+		if _, err := regexp.Compile(healthcheck.Log); err != nil {
+			return fmt.Errorf("healthcheck Log %v", err)
+		}
+	}
+	return nil
+}
+
+func parseHealthcheckDuration(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("healthcheck %v %q must be a duration such as 10s", name, value)
+	}
+	return duration, nil
+}
+
+// Docker healthcheck of the container config, nil keeps the image healthcheck
+func (healthcheck Healthcheck) DockerHealthcheck() (*container.HealthConfig, error) {
+	interval, err := parseHealthcheckDuration("Interval", healthcheck.Interval)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseHealthcheckDuration("Timeout", healthcheck.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	startPeriod, err := parseHealthcheckDuration("StartPeriod", healthcheck.StartPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if healthcheck.Retries < 0 {
+		return nil, fmt.Errorf("healthcheck Retries %v must be positive", healthcheck.Retries)
+	}
+	// Docker rejects intervals under a millisecond
+	for _, duration := range []time.Duration{interval, timeout, startPeriod} {
+		if duration > 0 && duration < time.Millisecond {
+			return nil, fmt.Errorf("healthcheck durations must be at least 1ms")
+		}
+	}
+	if len(healthcheck.Test) == 0 {
+		if interval != 0 || timeout != 0 || startPeriod != 0 || healthcheck.Retries != 0 {
+			if healthcheck.HTTP == "" && healthcheck.TCP == "" && healthcheck.Log == "" {
+				return nil, fmt.Errorf("healthcheck needs a Test, HTTP, TCP or Log")
+			}
+		}
+		return nil, nil
+	}
+	return &container.HealthConfig{
+		Test:        healthcheck.Test,
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+		Retries:     healthcheck.Retries,
+	}, nil
+}
+
+// State of a started container
+type ContainerState struct {
+	Running  bool
+	ExitCode int
+	// Docker health, empty when the container has no healthcheck
+	Health string
+}
+
+// Reads the state and logs of the started containers, Docker or a fake in tests
+type ReadinessRuntime interface {
+	State(ctx context.Context, cont *Container) (ContainerState, error)
+	Logs(ctx context.Context, cont *Container) (string, error)
+}
+
+// Time between two readiness checks of the containers
+var readyPollInterval = time.Second
+
+// Wait until every container is ready: running, healthy when Docker runs a
+// healthcheck and passing its host probes. A container that exited with code
+// 0 and has no healthcheck is done and counts as ready.
+func (containerArray *Containers) WaitReady(ctx context.Context, runtime ReadinessRuntime, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ready := make([]bool, len(containerArray.Containers))
+	waiting := map[string]string{}
+	for {
+		for contIndex, _ := range containerArray.Containers {
+			cont := &containerArray.Containers[contIndex]
+			if ready[contIndex] || cont.ContainerID == "" {
+				ready[contIndex] = true
+				continue
+			}
+			isReady, reason, err := cont.checkReady(ctx, runtime)
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			ready[contIndex] = isReady
+			waiting[cont.Name] = reason
+		}
+
+		var notReady []string
+		for contIndex, cont := range containerArray.Containers {
+			if !ready[contIndex] {
+				notReady = append(notReady, fmt.Sprintf("%v (%v)", cont.Name, waiting[cont.Name]))
+			}
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Containers not ready after %v: %v", timeout, strings.Join(notReady, ", "))
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// Check a container once, the reason tells what it is waiting for
+func (cont *Container) checkReady(ctx context.Context, runtime ReadinessRuntime) (bool, string, error) {
+	state, err := runtime.State(ctx, cont)
+	if err != nil {
+		return false, "", err
+	}
+	healthcheck := Healthcheck{}
+	if cont.Healthcheck != nil {
+		healthcheck = *cont.Healthcheck
+	}
+	hasProbe := healthcheck.HTTP != "" || healthcheck.TCP != "" || healthcheck.Log != ""
+	if !state.Running {
+		if state.ExitCode == 0 && state.Health == "" && !hasProbe {
+			return true, "", nil
+		}
+		return false, "", fmt.Errorf("Container %v exited with code %d before it was ready", cont.Name, state.ExitCode)
+	}
+	switch state.Health {
+	case HealthUnhealthy:
+		return false, "", fmt.Errorf("Container %v is unhealthy", cont.Name)
+	case HealthStarting:
+		return false, "healthcheck starting", nil
+	}
+
+	timeout, _ := parseHealthcheckDuration("Timeout", healthcheck.Timeout)
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	if healthcheck.HTTP != "" {
+		if err := probeHTTP(ctx, healthcheck.HTTP, timeout); err != nil {
+			return false, err.Error(), nil
+		}
+	}
+	if healthcheck.TCP != "" {
+		connection, err := net.DialTimeout("tcp", healthcheck.TCP, timeout)
+		if err != nil {
+			return false, "TCP " + healthcheck.TCP + " not accepting connections", nil
+		}
+		connection.Close()
+	}
+	if healthcheck.Log != "" {
+		logs, err := runtime.Logs(ctx, cont)
+		if err != nil {
+			return false, "", err
+		}
+		if !regexp.MustCompile("(?m)" + healthcheck.Log).MatchString(logs) {
+			return false, "no log line matching " + healthcheck.Log, nil
+		}
+	}
+	return true, "", nil
+}
+
+func probeHTTP(ctx context.Context, probeURL string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("HTTP %v not answering", probeURL)
+	}
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %v answered %v", probeURL, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Runtime whose container states and logs are set by the test
+type fakeReadinessRuntime struct {
+	lock   sync.Mutex
+	states map[string]ContainerState
+	logs   map[string]string
+}
+
+func (fake *fakeReadinessRuntime) State(ctx context.Context, cont *Container) (ContainerState, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	state, ok := fake.states[cont.Name]
+	if !ok {
+		return ContainerState{}, fmt.Errorf("no container %v", cont.Name)
+	}
+	return state, nil
+}
+
+func (fake *fakeReadinessRuntime) Logs(ctx context.Context, cont *Container) (string, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.logs[cont.Name], nil
+}
+
+func (fake *fakeReadinessRuntime) set(name string, state ContainerState, logs string) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.states[name] = state
+	fake.logs[name] = logs
+}
+
+// TestHealthcheckYaml: test parsing healthchecks from the profile
+func TestHealthcheckYaml(t *testing.T) {
+	tests := []struct {
+		name                string
+		healthcheckYaml     string
+		expectedErr         bool
+		expectedHealthcheck Healthcheck
+	}{
+		{"valid shell test", "Test: curl -f http://localhost:8080\nInterval: 10s\nRetries: 3\n", false, Healthcheck{Test: HealthcheckTest{"CMD-SHELL", "curl -f http://localhost:8080"}, Interval: "10s", Retries: 3}},
+		{"valid exec test", "Test: [pgrep, gst-launch]\n", false, Healthcheck{Test: HealthcheckTest{"CMD", "pgrep", "gst-launch"}}},
+		{"valid CMD test", "Test: [CMD, pgrep, gst-launch]\n", false, Healthcheck{Test: HealthcheckTest{"CMD", "pgrep", "gst-launch"}}},
+		{"valid none", "Test: NONE\n", false, Healthcheck{Test: HealthcheckTest{"NONE"}}},
+		{"valid probes", "HTTP: http://localhost:8080/health\nTCP: localhost:1883\nLog: pipeline started\nTimeout: 2s\n", false, Healthcheck{HTTP: "http://localhost:8080/health", TCP: "localhost:1883", Log: "pipeline started", Timeout: "2s"}},
+		{"invalid interval", "Test: true\nInterval: often\n", true, Healthcheck{}},
+		{"invalid retries", "Test: true\nRetries: -1\n", true, Healthcheck{}},
+		{"invalid timing without test", "Interval: 10s\n", true, Healthcheck{}},
+		{"invalid HTTP", "HTTP: localhost:8080\n", true, Healthcheck{}},
+		{"invalid TCP", "TCP: localhost\n", true, Healthcheck{}},
+		{"invalid log", "Log: \"(started\"\n", true, Healthcheck{}},
+		{"invalid test", "Test: {cmd: true}\n", true, Healthcheck{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthcheck := Healthcheck{}
+			hasError := false
+			if err := yaml.Unmarshal([]byte(tt.healthcheckYaml), &healthcheck); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedHealthcheck, healthcheck)
+			}
+		})
+	}
+}
+
+// TestDockerHealthcheck: test the Docker healthcheck set in the container config
+func TestDockerHealthcheck(t *testing.T) {
+	tests := []struct {
+		name                string
+		healthcheck         *Healthcheck
+		expectedHealthcheck *container.HealthConfig
+	}{
+		{"no healthcheck", nil, nil},
+		{"probes only", &Healthcheck{HTTP: "http://localhost:8080"}, nil},
+		{"test with timings", &Healthcheck{Test: HealthcheckTest{"CMD-SHELL", "true"}, Interval: "5s", Timeout: "1s", Retries: 2, StartPeriod: "30s"}, &container.HealthConfig{Test: []string{"CMD-SHELL", "true"}, Interval: 5 * time.Second, Timeout: time.Second, Retries: 2, StartPeriod: 30 * time.Second}},
+		{"disabled", &Healthcheck{Test: HealthcheckTest{"NONE"}}, &container.HealthConfig{Test: []string{"NONE"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cont := Container{Healthcheck: tt.healthcheck}
+			require.Equal(t, tt.expectedHealthcheck, cont.DockerHealthcheck())
+		})
+	}
+}
+
+// TestWaitReady: test waiting for container health and host probes
+func TestWaitReady(t *testing.T) {
+	defer func(interval time.Duration) { readyPollInterval = interval }(readyPollInterval)
+	readyPollInterval = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/health" {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := closedListener.Addr().String()
+	closedListener.Close()
+
+	running := ContainerState{Running: true}
+	tests := []struct {
+		name        string
+		healthcheck *Healthcheck
+		state       ContainerState
+		logs        string
+		expectedErr bool
+	}{
+		{"ready running", nil, running, "", false},
+		{"ready healthy", nil, ContainerState{Running: true, Health: HealthHealthy}, "", false},
+		{"ready exited without probes", nil, ContainerState{ExitCode: 0}, "", false},
+		{"ready HTTP", &Healthcheck{HTTP: server.URL + "/health"}, running, "", false},
+		{"ready TCP", &Healthcheck{TCP: listener.Addr().String()}, running, "", false},
+		{"ready log", &Healthcheck{Log: "^pipeline started$"}, running, "loading\npipeline started\n", false},
+		{"not ready starting", nil, ContainerState{Running: true, Health: HealthStarting}, "", true},
+		{"not ready unhealthy", nil, ContainerState{Running: true, Health: HealthUnhealthy}, "", true},
+		{"not ready exited with error", nil, ContainerState{ExitCode: 1}, "", true},
+		{"not ready exited with probe", &Healthcheck{Log: "started"}, ContainerState{ExitCode: 0}, "started\n", true},
+		{"not ready HTTP error", &Healthcheck{HTTP: server.URL + "/missing"}, running, "", true},
+		{"not ready TCP closed", &Healthcheck{TCP: closedAddress}, running, "", true},
+		{"not ready log", &Healthcheck{Log: "^pipeline started$"}, running, "loading\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := Containers{Containers: []Container{{Name: "Camera", ContainerID: "id-Camera", Healthcheck: tt.healthcheck}}}
+			fake := &fakeReadinessRuntime{states: map[string]ContainerState{}, logs: map[string]string{}}
+			fake.set("Camera", tt.state, tt.logs)
+			hasError := false
+			if err := tmpContainers.WaitReady(context.Background(), fake, 50*time.Millisecond); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+		})
+	}
+}
+
+// TestWaitReadyBecomesReady: test waiting for a container that becomes healthy
+func TestWaitReadyBecomesReady(t *testing.T) {
+	defer func(interval time.Duration) { readyPollInterval = interval }(readyPollInterval)
+	readyPollInterval = time.Millisecond
+
+	tmpContainers := Containers{Containers: []Container{
+		{Name: "Broker", ContainerID: "id-Broker"},
+		{Name: "Camera", ContainerID: "id-Camera", Healthcheck: &Healthcheck{Log: "streaming"}},
+		{Name: "Setup"},
+	}}
+	fake := &fakeReadinessRuntime{states: map[string]ContainerState{}, logs: map[string]string{}}
+	fake.set("Broker", ContainerState{Running: true, Health: HealthStarting}, "")
+	fake.set("Camera", ContainerState{Running: true}, "")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		fake.set("Broker", ContainerState{Running: true, Health: HealthHealthy}, "")
+		fake.set("Camera", ContainerState{Running: true}, "streaming\n")
+	}()
+	require.NoError(t, tmpContainers.WaitReady(context.Background(), fake, 5*time.Second))
+
+	fake.set("Camera", ContainerState{Running: true}, "")
+	err := tmpContainers.WaitReady(context.Background(), fake, 20*time.Millisecond)
+	require.EqualError(t, err, "Containers not ready after 20ms: Camera (no log line matching streaming)")
+}
@@ -0,0 +1,298 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Points of a launch hooks run at
+const (
+	// Before the images are pulled and the containers are started
+	HookPrePull = "PrePull"
+	// After the container named by the hook started
+	HookPostStart = "PostStart"
+	// Once every container is ready
+	HookPostReady = "PostReady"
+	// Once the containers stopped, before they are removed
+	HookPostStop = "PostStop"
+)
+
+// What a failing hook does to the launch
+const (
+	HookOnFailureFail     = "fail"
+	HookOnFailureContinue = "continue"
+)
+
+// Log of the hook output written to the run directory
+const HookLogFile = "hooks.log"
+
+// Hooks of the profile by the point of the launch they run at
+type Hooks struct {
+	PrePull   []Hook `yaml:"PrePull" json:"prePull,omitempty"`
+	PostStart []Hook `yaml:"PostStart" json:"postStart,omitempty"`
+	PostReady []Hook `yaml:"PostReady" json:"postReady,omitempty"`
+	PostStop  []Hook `yaml:"PostStop" json:"postStop,omitempty"`
+}
+
+// Command run on the host from the profile directory, or inside Container
+// with Exec. Host commands get the PROFILE_* envs of the launch.
+type Hook struct {
+	Name    string      `yaml:"Name" json:"name,omitempty"`
+	Command CommandArgs `yaml:"Command" json:"command"`
+	// Container the hook is about, every replica of it with Replicas
+	Container string `yaml:"Container" json:"container,omitempty"`
+	Exec      bool   `yaml:"Exec" json:"exec,omitempty"`
+	// Longest time the command can run, no limit when empty
+	Timeout string `yaml:"Timeout" json:"timeout,omitempty"`
+	// fail (default) ends the launch with an error, continue only warns
+	OnFailure string `yaml:"OnFailure" json:"onFailure,omitempty"`
+	// Directory a host command runs from, relative to the profile
+	// directory. Hooks inherited from another profile run from its directory.
+	Dir string `yaml:"Dir" json:"dir,omitempty"`
+}
+
+// Where the hooks of a launch run and write their output
+type HookContext struct {
+	// Directory host commands run from
+	ConfigDir string
+	RunID     string
+	// Directory hooks.log is written to, the output is dropped when empty
+	LogDir string
+}
+
+// Runs the exec hooks, Docker or a fake in tests
+type HookRuntime interface {
+	Exec(ctx context.Context, cont *Container, command []string) (int, string, error)
+}
+
+// Hooks run at a point of the launch
+func (hooks *Hooks) stage(stage string) []Hook {
+	if hooks == nil {
+		return nil
+	}
+	switch stage {
+	case HookPrePull:
+		return hooks.PrePull
+	case HookPostStart:
+		return hooks.PostStart
+	case HookPostReady:
+		return hooks.PostReady
+	case HookPostStop:
+		return hooks.PostStop
+	}
+	return nil
+}
+
+// Name of the hook in the output
+func (hook Hook) name() string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return strings.Join(hook.Command, " ")
+}
+
+// Whether the hook is about the container or one of its replicas
+func (hook Hook) matches(cont *Container) bool {
+	return hook.Container == cont.Name || (cont.Replica > 0 && cont.Name == fmt.Sprintf("%v-%d", hook.Container, cont.Replica))
+}
+
+// Check the hooks of the resolved containers and run host commands from
+// the profile directory
+func (containerArray *Containers) SetHooks(configDir string) error {
+	absConfigDir, err := filepath.Abs(configDir)
+	if err != nil {
+		return err
+	}
+	containerArray.HookContext.ConfigDir = absConfigDir
+
+	for _, stage := range []string{HookPrePull, HookPostStart, HookPostReady, HookPostStop} {
+		for _, hook := range containerArray.Hooks.stage(stage) {
+			if len(hook.Command) == 0 {
+				return fmt.Errorf("%v hook %q has no Command", stage, hook.Name)
+			}
+			if _, err := hook.timeout(); err != nil {
+				return fmt.Errorf("%v hook %v: %v", stage, hook.name(), err)
+			}
+			if hook.OnFailure != "" && hook.OnFailure != HookOnFailureFail && hook.OnFailure != HookOnFailureContinue {
+				return fmt.Errorf("%v hook %v: OnFailure %q must be fail or continue", stage, hook.name(), hook.OnFailure)
+			}
+			if hook.Container == "" {
+				if stage == HookPostStart || hook.Exec {
+					return fmt.Errorf("%v hook %v needs a Container", stage, hook.name())
+				}
+				continue
+			}
+			if !slices.ContainsFunc(containerArray.Containers, func(cont Container) bool { return hook.matches(&cont) }) {
+				return fmt.Errorf("%v hook %v: container %v not found", stage, hook.name(), hook.Container)
+			}
+			// Nothing runs in the containers before they start or once they stopped
+			if hook.Exec && (stage == HookPrePull || stage == HookPostStop) {
+				return fmt.Errorf("%v hook %v can't Exec in a container", stage, hook.name())
+			}
+		}
+	}
+	return nil
+}
+
+func (hook Hook) timeout() (time.Duration, error) {
+	if hook.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(hook.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("Timeout %q must be a duration such as 5m", hook.Timeout)
+	}
+	return timeout, nil
+}
+
+// Run the hooks of a stage in order. With cont set only the hooks about it
+// run, otherwise a hook about a container runs once per started replica.
+// A failing hook stops the stage unless its OnFailure is continue.
+func (containerArray *Containers) RunHooks(ctx context.Context, runtime HookRuntime, stage string, cont *Container) error {
+	for _, hook := range containerArray.Hooks.stage(stage) {
+		var targets []*Container
+		if hook.Container == "" {
+			if cont != nil {
+				continue
+			}
+			targets = append(targets, nil)
+		} else if cont != nil {
+			if !hook.matches(cont) {
+				continue
+			}
+			targets = append(targets, cont)
+		} else {
+			for contIndex, _ := range containerArray.Containers {
+				target := &containerArray.Containers[contIndex]
+				if target.ContainerID != "" && hook.matches(target) {
+					targets = append(targets, target)
+				}
+			}
+		}
+
+		for _, target := range targets {
+			err := containerArray.runHook(ctx, runtime, stage, hook, target)
+			if err == nil {
+				continue
+			}
+			if hook.OnFailure == HookOnFailureContinue {
+				fmt.Println("Warning:", MaskSecrets(err.Error()))
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (containerArray *Containers) runHook(ctx context.Context, runtime HookRuntime, stage string, hook Hook, cont *Container) error {
+	name := hook.name()
+	if cont != nil {
+		name += " (" + cont.Name + ")"
+	}
+	fmt.Printf("Running %v hook %v\n", stage, name)
+
+	timeout, _ := hook.timeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	startTime := time.Now()
+	var exitCode int
+	var output string
+	var err error
+	if hook.Exec {
+		exitCode, output, err = runtime.Exec(ctx, cont, hook.Command)
+	} else {
+		exitCode, output, err = containerArray.runHostHook(ctx, hook, cont)
+	}
+	duration := time.Since(startTime).Round(time.Millisecond)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%v hook %v still running after %v", stage, name, timeout)
+	} else if err != nil {
+		err = fmt.Errorf("%v hook %v failed %v", stage, name, err)
+	} else if exitCode != 0 {
+		err = fmt.Errorf("%v hook %v exited with code %d", stage, name, exitCode)
+	}
+	if logErr := containerArray.writeHookLog(stage, name, output, exitCode, duration); logErr != nil {
+		fmt.Println(logErr)
+	}
+	return err
+}
+
+// Run a host hook from its directory with the envs of the launch
+func (containerArray *Containers) runHostHook(ctx context.Context, hook Hook, cont *Container) (int, string, error) {
+	hookContext := containerArray.HookContext
+	command := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	command.Dir = hookContext.ConfigDir
+	if hook.Dir != "" {
+		command.Dir = hook.Dir
+		if !filepath.IsAbs(hook.Dir) {
+			command.Dir = filepath.Join(hookContext.ConfigDir, hook.Dir)
+		}
+	}
+	command.Env = append(os.Environ(),
+		"PROFILE_CONFIG_DIR="+hookContext.ConfigDir,
+		"PROFILE_RUN_ID="+hookContext.RunID,
+		"PROFILE_RUN_DIR="+hookContext.LogDir)
+	if cont != nil {
+		command.Env = append(command.Env,
+			"PROFILE_CONTAINER="+cont.Name,
+			"PROFILE_CONTAINER_ID="+cont.ContainerID)
+	}
+	var output bytes.Buffer
+	command.Stdout = &output
+	command.Stderr = &output
+	err := command.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), output.String(), nil
+	}
+	return 0, output.String(), err
+}
+
+// Append the output of a hook to hooks.log, secrets masked
+func (containerArray *Containers) writeHookLog(stage string, name string, output string, exitCode int, duration time.Duration) error {
+	logDir := containerArray.HookContext.LogDir
+	if logDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("Failed to create log directory %v", err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(logDir, HookLogFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to open hook log %v", err)
+	}
+	defer logFile.Close()
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	_, err = fmt.Fprintf(logFile, "=== %v hook %v\n%v=== exit code %d after %v\n", stage, name, MaskSecrets(output), exitCode, duration)
+	return err
+}
@@ -0,0 +1,165 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Runtime recording the exec hooks run in the test containers
+type fakeHookRuntime struct {
+	execs    []string
+	exitCode int
+}
+
+func (fake *fakeHookRuntime) Exec(ctx context.Context, cont *Container, command []string) (int, string, error) {
+	fake.execs = append(fake.execs, fmt.Sprintf("%v %v", cont.Name, command))
+	return fake.exitCode, "exec output", nil
+}
+
+// Containers with a replicated Client, all started
+func createHookContainers(t *testing.T, hooks *Hooks) Containers {
+	containerArray := Containers{
+		Hooks: hooks,
+		Containers: []Container{
+			{Name: "Server", ContainerID: "id-Server"},
+			{Name: "Client-1", Replica: 1, ContainerID: "id-Client-1"},
+			{Name: "Client-2", Replica: 2, ContainerID: "id-Client-2"},
+		},
+	}
+	require.NoError(t, containerArray.SetHooks(t.TempDir()))
+	containerArray.HookContext.RunID = "20240612-153012-1a2b3c4d"
+	containerArray.HookContext.LogDir = t.TempDir()
+	return containerArray
+}
+
+// TestSetHooks: test checking the hooks of the profile
+func TestSetHooks(t *testing.T) {
+	command := CommandArgs{"true"}
+	tests := []struct {
+		name        string
+		hooks       *Hooks
+		expectedErr bool
+	}{
+		{"valid no hooks", nil, false},
+		{"valid host hooks", &Hooks{PrePull: []Hook{{Command: command}}, PostStop: []Hook{{Command: command, Container: "Server", OnFailure: HookOnFailureContinue}}}, false},
+		{"valid exec hooks", &Hooks{PostStart: []Hook{{Command: command, Container: "Server", Exec: true, Timeout: "30s"}}, PostReady: []Hook{{Command: command, Container: "Client", Exec: true}}}, false},
+		{"invalid no command", &Hooks{PrePull: []Hook{{Name: "models"}}}, true},
+		{"invalid timeout", &Hooks{PrePull: []Hook{{Command: command, Timeout: "soon"}}}, true},
+		{"invalid on failure", &Hooks{PrePull: []Hook{{Command: command, OnFailure: "retry"}}}, true},
+		{"invalid post start without container", &Hooks{PostStart: []Hook{{Command: command}}}, true},
+		{"invalid exec without container", &Hooks{PostReady: []Hook{{Command: command, Exec: true}}}, true},
+		{"invalid unknown container", &Hooks{PostReady: []Hook{{Command: command, Container: "Camera"}}}, true},
+		{"invalid exec before start", &Hooks{PrePull: []Hook{{Command: command, Container: "Server", Exec: true}}}, true},
+		{"invalid exec after stop", &Hooks{PostStop: []Hook{{Command: command, Container: "Server", Exec: true}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerArray := Containers{Hooks: tt.hooks, Containers: []Container{
+				{Name: "Server"},
+				{Name: "Client-1", Replica: 1},
+				{Name: "Client-2", Replica: 2},
+			}}
+			hasError := false
+			if err := containerArray.SetHooks("."); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.True(t, filepath.IsAbs(containerArray.HookContext.ConfigDir))
+			}
+		})
+	}
+}
+
+// TestRunHostHooks: test host hooks getting the launch envs and logging their output
+func TestRunHostHooks(t *testing.T) {
+	containerArray := createHookContainers(t, &Hooks{
+		PostReady: []Hook{
+			{Name: "launch", Command: CommandArgs{"sh", "-c", "echo $PROFILE_RUN_ID $PROFILE_RUN_DIR; pwd"}},
+			{Name: "copy", Command: CommandArgs{"sh", "-c", "echo $PROFILE_CONTAINER $PROFILE_CONTAINER_ID"}, Container: "Client"},
+		},
+	})
+	hookContext := containerArray.HookContext
+	require.NoError(t, containerArray.RunHooks(context.Background(), &fakeHookRuntime{}, HookPostReady, nil))
+
+	hookLog, err := os.ReadFile(filepath.Join(hookContext.LogDir, HookLogFile))
+	require.NoError(t, err)
+	require.Contains(t, string(hookLog), "=== PostReady hook launch\n"+hookContext.RunID+" "+hookContext.LogDir+"\n"+hookContext.ConfigDir+"\n=== exit code 0")
+	// A hook about a replicated container runs for each replica
+	require.Contains(t, string(hookLog), "=== PostReady hook copy (Client-1)\nClient-1 id-Client-1\n")
+	require.Contains(t, string(hookLog), "=== PostReady hook copy (Client-2)\nClient-2 id-Client-2\n")
+}
+
+// TestRunHookFailures: test the failure policies and timeouts of hooks
+func TestRunHookFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		hooks         []Hook
+		expectedErr   string
+		expectedCalls int
+	}{
+		{"fail stops the stage", []Hook{{Name: "fails", Command: CommandArgs{"sh", "-c", "exit 3"}}, {Command: CommandArgs{"touch", "second"}}}, "PrePull hook fails exited with code 3", 0},
+		{"continue runs the next hook", []Hook{{Name: "fails", Command: CommandArgs{"sh", "-c", "exit 3"}, OnFailure: HookOnFailureContinue}, {Command: CommandArgs{"touch", "second"}}}, "", 1},
+		{"missing command", []Hook{{Name: "missing", Command: CommandArgs{"./missing-hook.sh"}}}, "PrePull hook missing failed", 0},
+		{"timeout", []Hook{{Name: "slow", Command: CommandArgs{"sleep", "10"}, Timeout: "50ms"}}, "PrePull hook slow still running after 50ms", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerArray := createHookContainers(t, &Hooks{PrePull: tt.hooks})
+			err := containerArray.RunHooks(context.Background(), &fakeHookRuntime{}, HookPrePull, nil)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.True(t, strings.HasPrefix(err.Error(), tt.expectedErr), err.Error())
+			}
+			second, _ := filepath.Glob(filepath.Join(containerArray.HookContext.ConfigDir, "second"))
+			require.Len(t, second, tt.expectedCalls)
+		})
+	}
+}
+
+// TestRunExecHooks: test exec hooks running in the container that started
+func TestRunExecHooks(t *testing.T) {
+	containerArray := createHookContainers(t, &Hooks{
+		PostStart: []Hook{
+			{Name: "warm", Command: CommandArgs{"warm-cache"}, Container: "Client", Exec: true},
+			{Name: "seed", Command: CommandArgs{"seed"}, Container: "Server", Exec: true},
+		},
+		PostReady: []Hook{{Command: CommandArgs{"check"}, Container: "Client", Exec: true}},
+	})
+	fake := &fakeHookRuntime{}
+	require.NoError(t, containerArray.RunHooks(context.Background(), fake, HookPostStart, &containerArray.Containers[1]))
+	require.Equal(t, []string{"Client-1 [warm-cache]"}, fake.execs)
+
+	fake.execs = nil
+	containerArray.Containers[2].ContainerID = ""
+	require.NoError(t, containerArray.RunHooks(context.Background(), fake, HookPostReady, nil))
+	require.Equal(t, []string{"Client-1 [check]"}, fake.execs)
+
+	fake.exitCode = 1
+	require.EqualError(t, containerArray.RunHooks(context.Background(), fake, HookPostStart, &containerArray.Containers[0]), "PostStart hook seed (Server) exited with code 1")
+}
@@ -0,0 +1,204 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of input sources
+const (
+	InputCamera    = "camera"
+	InputStream    = "stream"
+	InputFile      = "file"
+	InputDirectory = "directory"
+	InputOther     = "other"
+)
+
+// Directory local input files and directories are mounted under in the container
+const InputMountDir = "/input"
+
+// Input source detected from the --inputsrc value
+type InputSource struct {
+	Kind string
+	// Value passed to the container as INPUTSRC
+	Value string
+	// Camera device or local path on the host
+	HostPath string
+}
+
+// ENV containers receive their input source in unless they set EnvName
+const DefaultInputEnv = "INPUTSRC"
+
+var videoNodePattern = regexp.MustCompile(`^video[0-9]+$`)
+
+// Prefix of input sources picking a capture camera by its position
+const autoCameraPrefix = "auto:"
+
+// Accept "InputSrc: none" as well as the mapping form
+func (input *ContainerInput) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		if node.Value != "none" {
+			return fmt.Errorf("line %d: InputSrc must be none or a mapping with Required, EnvName and Source", node.Line)
+		}
+		*input = ContainerInput{Disabled: true}
+		return nil
+	}
+	type rawContainerInput ContainerInput
+	return node.Decode((*rawContainerInput)(input))
+}
+
+// ENV the container receives its input source in
+func (input *ContainerInput) GetEnvName() string {
+	if input.EnvName == "" {
+		return DefaultInputEnv
+	}
+	return input.EnvName
+}
+
+// Detect and validate the kind of an input source: a V4L2 camera, given by
+// its node or as auto:N for the Nth capture camera, an RTSP
+// or HTTP stream, a local file or directory, or anything else such as a
+// GStreamer element which is passed as is
+func ParseInputSource(inputSrc string) (InputSource, error) {
+	inputSource := InputSource{Kind: InputOther, Value: inputSrc}
+
+	if strings.Contains(inputSrc, "://") {
+		inputURL, err := url.Parse(inputSrc)
+		if err != nil {
+			return inputSource, fmt.Errorf("Input source %v is not a valid URL: %v", inputSrc, err)
+		}
+		switch inputURL.Scheme {
+		case "rtsp", "rtsps", "http", "https":
+			if inputURL.Hostname() == "" {
+				return inputSource, fmt.Errorf("Input source %v has no host", inputSrc)
+			}
+			inputSource.Kind = InputStream
+			return inputSource, nil
+		case "file":
+			return parseLocalInput(inputURL.Path)
+		default:
+			return inputSource, fmt.Errorf("Input source %v: scheme %v not supported, use rtsp, rtsps, http, https or file", inputSrc, inputURL.Scheme)
+		}
+	}
+
+	if number, found := strings.CutPrefix(inputSrc, autoCameraPrefix); found {
+		index, err := strconv.Atoi(number)
+		if err != nil || index < 0 {
+			return inputSource, fmt.Errorf("Input source %v: auto needs a camera number such as auto:0", inputSrc)
+		}
+		camera, err := FindCaptureCamera(index)
+		if err != nil {
+			return inputSource, err
+		}
+		return parseCameraInput(camera.Node)
+	}
+	if strings.HasPrefix(inputSrc, "/dev/") {
+		return parseCameraInput(inputSrc)
+	}
+	if isLocalPath(inputSrc) {
+		return parseLocalInput(inputSrc)
+	}
+	// A GStreamer pipeline such as filesrc location=/data/a.mp4 ! decodebin
+	// has slashes too, it is only taken for a path when one exists
+	if strings.Contains(inputSrc, "/") {
+		if _, err := os.Stat(inputSrc); err == nil {
+			return parseLocalInput(inputSrc)
+		}
+	}
+	return inputSource, nil
+}
+
+// Whether an input source can only be meant as a local path: it starts like
+// one, or is a single word with a slash
+func isLocalPath(inputSrc string) bool {
+	for _, prefix := range []string{"/", "./", "../", "~"} {
+		if strings.HasPrefix(inputSrc, prefix) {
+			return true
+		}
+	}
+	if inputSrc == "." || inputSrc == ".." {
+		return true
+	}
+	return strings.Contains(inputSrc, "/") && !strings.ContainsAny(inputSrc, " \t\n!")
+}
+
+// Camera given as /dev/videoN or a udev symlink such as /dev/v4l/by-id/...
+func parseCameraInput(inputSrc string) (InputSource, error) {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(devRoot, strings.TrimPrefix(inputSrc, "/dev/")))
+	if err != nil {
+		return InputSource{}, fmt.Errorf("Camera %v does not exist", inputSrc)
+	}
+	node := filepath.Base(resolved)
+	if !videoNodePattern.MatchString(node) {
+		return InputSource{}, fmt.Errorf("Input source %v is not a /dev/videoN camera", inputSrc)
+	}
+	devicePath := "/dev/" + node
+	if err := CheckCaptureDevice(devicePath); err != nil {
+		return InputSource{}, err
+	}
+	return InputSource{Kind: InputCamera, Value: devicePath, HostPath: devicePath}, nil
+}
+
+// Local file or directory, mounted read-only under /input in the container
+func parseLocalInput(inputPath string) (InputSource, error) {
+	hostPath, err := filepath.Abs(expandHome(inputPath))
+	if err != nil {
+		return InputSource{}, fmt.Errorf("Failed to get input source path %v", err)
+	}
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return InputSource{}, fmt.Errorf("Input source %v does not exist", inputPath)
+	}
+
+	inputSource := InputSource{
+		Kind:     InputFile,
+		Value:    path.Join(InputMountDir, filepath.Base(hostPath)),
+		HostPath: hostPath,
+	}
+	if info.IsDir() {
+		inputSource.Kind = InputDirectory
+	}
+	return inputSource, nil
+}
+
+// Map the input source into the container and set its ENV
+func (cont *Container) SetInputSource(envName string, inputSource InputSource) {
+	switch inputSource.Kind {
+	case InputCamera:
+		cont.AddHostDevice(inputSource.HostPath)
+	case InputFile, InputDirectory:
+		cont.HostConfig.Mounts = append(cont.HostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   inputSource.HostPath,
+			Target:   inputSource.Value,
+			ReadOnly: true,
+		})
+	}
+	cont.SetEnv(envName, inputSource.Value)
+}
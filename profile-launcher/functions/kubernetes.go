@@ -0,0 +1,383 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"gopkg.in/yaml.v3"
+)
+
+// Resource requested from the Intel GPU device plugin for containers using a GPU
+const IntelGpuResource = "gpu.intel.com/i915"
+
+// Kubernetes object kinds supported by the export
+const (
+	KubernetesPod        = "Pod"
+	KubernetesDeployment = "Deployment"
+)
+
+// Minimal Kubernetes manifest types, only the fields set by the export
+type k8sMetadata struct {
+	Name   string            `yaml:"name,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+type k8sConfigMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sPod struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMetadata `yaml:"metadata"`
+	Spec       k8sPodSpec  `yaml:"spec"`
+}
+
+type k8sDeployment struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Spec       k8sDeploymentSpec `yaml:"spec"`
+}
+
+type k8sDeploymentSpec struct {
+	Replicas int `yaml:"replicas"`
+	Selector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	} `yaml:"selector"`
+	Template struct {
+		Metadata k8sMetadata `yaml:"metadata"`
+		Spec     k8sPodSpec  `yaml:"spec"`
+	} `yaml:"template"`
+}
+
+type k8sPodSpec struct {
+	HostNetwork   bool           `yaml:"hostNetwork,omitempty"`
+	HostIPC       bool           `yaml:"hostIPC,omitempty"`
+	RestartPolicy string         `yaml:"restartPolicy,omitempty"`
+	Containers    []k8sContainer `yaml:"containers"`
+	Volumes       []k8sVolume    `yaml:"volumes,omitempty"`
+}
+
+type k8sContainer struct {
+	Name            string              `yaml:"name"`
+	Image           string              `yaml:"image"`
+	Command         []string            `yaml:"command,omitempty"`
+	Args            []string            `yaml:"args,omitempty"`
+	WorkingDir      string              `yaml:"workingDir,omitempty"`
+	TTY             bool                `yaml:"tty,omitempty"`
+	Ports           []k8sContainerPort  `yaml:"ports,omitempty"`
+	Env             []k8sEnvVar         `yaml:"env,omitempty"`
+	EnvFrom         []k8sEnvFrom        `yaml:"envFrom,omitempty"`
+	VolumeMounts    []k8sVolumeMount    `yaml:"volumeMounts,omitempty"`
+	Resources       *k8sResources       `yaml:"resources,omitempty"`
+	SecurityContext *k8sSecurityContext `yaml:"securityContext,omitempty"`
+}
+
+type k8sContainerPort struct {
+	ContainerPort int    `yaml:"containerPort"`
+	Protocol      string `yaml:"protocol,omitempty"`
+}
+
+type k8sEnvFrom struct {
+	ConfigMapRef struct {
+		Name string `yaml:"name"`
+	} `yaml:"configMapRef"`
+}
+
+// Env taken from a key of a Secret
+type k8sEnvVar struct {
+	Name      string `yaml:"name"`
+	ValueFrom struct {
+		SecretKeyRef struct {
+			Name string `yaml:"name"`
+			Key  string `yaml:"key"`
+		} `yaml:"secretKeyRef"`
+	} `yaml:"valueFrom"`
+}
+
+type k8sVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	SubPath   string `yaml:"subPath,omitempty"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type k8sResources struct {
+	Limits map[string]string `yaml:"limits"`
+}
+
+type k8sSecurityContext struct {
+	Privileged bool `yaml:"privileged"`
+}
+
+type k8sVolume struct {
+	Name     string `yaml:"name"`
+	HostPath *struct {
+		Path string `yaml:"path"`
+		Type string `yaml:"type,omitempty"`
+	} `yaml:"hostPath,omitempty"`
+	EmptyDir *struct {
+		Medium    string `yaml:"medium,omitempty"`
+		SizeLimit string `yaml:"sizeLimit,omitempty"`
+	} `yaml:"emptyDir,omitempty"`
+	Secret *struct {
+		SecretName string `yaml:"secretName"`
+	} `yaml:"secret,omitempty"`
+}
+
+var (
+	k8sInvalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+	k8sConfigMapKey     = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// Convert a name into a valid Kubernetes DNS-1123 label
+func KubernetesName(name string) string {
+	name = k8sInvalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.Trim(name, "-")
+}
+
+// Generate Kubernetes manifests for the resolved containers, one ConfigMap
+// per container holding its env and a Pod or Deployment running them all.
+// Secrets are taken from a <name>-secrets Secret that isn't generated so
+// their values never end up in the manifests.
+func (containerArray *Containers) ExportKubernetes(profileName string, kind string) ([]byte, error) {
+	if kind != KubernetesPod && kind != KubernetesDeployment {
+		return nil, fmt.Errorf("Kubernetes kind %v not supported, use %v or %v", kind, KubernetesPod, KubernetesDeployment)
+	}
+	appName := KubernetesName(profileName)
+	if appName == "" {
+		return nil, fmt.Errorf("profile name %q is not a valid Kubernetes name", profileName)
+	}
+	labels := map[string]string{"app": appName}
+
+	var configMaps []k8sConfigMap
+	podSpec := k8sPodSpec{}
+	hostPathVolumes := map[string]string{}
+	secretName := appName + "-secrets"
+	secretVolume := false
+	for _, cont := range containerArray.Containers {
+		contName := KubernetesName(cont.Name)
+		if contName == "" {
+			return nil, fmt.Errorf("container name %q is not a valid Kubernetes name", cont.Name)
+		}
+
+		// Env becomes a ConfigMap
+		configMap := k8sConfigMap{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   k8sMetadata{Name: appName + "-" + contName + "-env", Labels: labels},
+			Data:       map[string]string{},
+		}
+		secretEnvs := map[string]bool{}
+		for _, contSecret := range cont.Secrets {
+			if !k8sConfigMapKey.MatchString(contSecret.Name) {
+				return nil, fmt.Errorf("secret %v of container %v is not a valid Secret key", contSecret.Name, cont.Name)
+			}
+			if contSecret.Env != "" {
+				secretEnvs[contSecret.Env] = true
+			}
+		}
+		for _, env := range cont.Envs {
+			key, value, found := strings.Cut(env, "=")
+			if !found || secretEnvs[key] {
+				continue
+			}
+			if !k8sConfigMapKey.MatchString(key) {
+				return nil, fmt.Errorf("env %v of container %v is not a valid ConfigMap key", key, cont.Name)
+			}
+			configMap.Data[key] = value
+		}
+		configMaps = append(configMaps, configMap)
+
+		k8sCont := k8sContainer{
+			Name:       contName,
+			Image:      cont.DockerImage,
+			Command:    cont.Entrypoint,
+			Args:       cont.Command,
+			WorkingDir: cont.WorkingDir,
+			TTY:        cont.Tty,
+		}
+		envFrom := k8sEnvFrom{}
+		envFrom.ConfigMapRef.Name = configMap.Metadata.Name
+		k8sCont.EnvFrom = append(k8sCont.EnvFrom, envFrom)
+		for _, contSecret := range cont.Secrets {
+			if contSecret.Env != "" {
+				envVar := k8sEnvVar{Name: contSecret.Env}
+				envVar.ValueFrom.SecretKeyRef.Name = secretName
+				envVar.ValueFrom.SecretKeyRef.Key = contSecret.Name
+				k8sCont.Env = append(k8sCont.Env, envVar)
+			}
+			if contSecret.File != "" {
+				secretVolume = true
+				k8sCont.VolumeMounts = append(k8sCont.VolumeMounts, k8sVolumeMount{Name: secretName, MountPath: contSecret.File, SubPath: contSecret.Name, ReadOnly: true})
+			}
+		}
+
+		// Bind mounts become hostPath volumes, shared between containers using the same path
+		addHostPath := func(path string, hostPathType string, mountPath string, readOnly bool) {
+			volumeName, ok := hostPathVolumes[path]
+			if !ok {
+				volumeName = fmt.Sprintf("%v-host-%d", appName, len(hostPathVolumes))
+				hostPathVolumes[path] = volumeName
+				volume := k8sVolume{Name: volumeName}
+				volume.HostPath = &struct {
+					Path string `yaml:"path"`
+					Type string `yaml:"type,omitempty"`
+				}{Path: path, Type: hostPathType}
+				podSpec.Volumes = append(podSpec.Volumes, volume)
+			}
+			k8sCont.VolumeMounts = append(k8sCont.VolumeMounts, k8sVolumeMount{Name: volumeName, MountPath: mountPath, ReadOnly: readOnly})
+		}
+		// Tmpfs mounts become memory backed emptyDir volumes of the container
+		for _, mnt := range cont.HostConfig.Mounts {
+			switch mnt.Type {
+			case mount.TypeBind:
+				addHostPath(mnt.Source, "", mnt.Target, mnt.ReadOnly)
+			case mount.TypeTmpfs:
+				volume := k8sVolume{Name: fmt.Sprintf("%v-%v-tmpfs-%d", appName, contName, len(k8sCont.VolumeMounts))}
+				volume.EmptyDir = &struct {
+					Medium    string `yaml:"medium,omitempty"`
+					SizeLimit string `yaml:"sizeLimit,omitempty"`
+				}{Medium: "Memory"}
+				if mnt.TmpfsOptions != nil && mnt.TmpfsOptions.SizeBytes > 0 {
+					volume.EmptyDir.SizeLimit = fmt.Sprint(mnt.TmpfsOptions.SizeBytes)
+				}
+				podSpec.Volumes = append(podSpec.Volumes, volume)
+				k8sCont.VolumeMounts = append(k8sCont.VolumeMounts, k8sVolumeMount{Name: volume.Name, MountPath: mnt.Target, ReadOnly: mnt.ReadOnly})
+			default:
+				return nil, fmt.Errorf("mount %v of container %v has type %v, only bind and tmpfs mounts can be exported", mnt.Target, cont.Name, mnt.Type)
+			}
+		}
+		// Binds relabeled for SELinux, the relabeling has no hostPath equivalent
+		for _, bind := range cont.HostConfig.Binds {
+			spec, err := ParseVolume(bind)
+			if err != nil {
+				return nil, fmt.Errorf("bind %v of container %v: %v", bind, cont.Name, err)
+			}
+			addHostPath(spec.Source, "", spec.Target, spec.ReadOnly)
+		}
+
+		// A GPU is requested from the Intel GPU device plugin when the target
+		// device uses one, the plugin picks the GPU so its render nodes are
+		// left out. Other devices are mounted from the host.
+		targetDevice := cont.TargetDevice
+		if targetDevice == "" {
+			targetDevice = containerArray.TargetDevice
+		}
+		gpuRequested := false
+		if targetDevice != "" {
+			parsed, err := ParseTargetDevice(targetDevice)
+			if err != nil {
+				return nil, fmt.Errorf("Container %v: %v", cont.Name, err)
+			}
+			gpuRequested = parsed.UsesGpu()
+		}
+		for _, device := range cont.HostConfig.Devices {
+			if strings.HasPrefix(device.PathOnHost, "/dev/dri/") {
+				continue
+			}
+			addHostPath(device.PathOnHost, "CharDevice", device.PathInContainer, false)
+		}
+		limits := map[string]string{}
+		if gpuRequested {
+			limits[IntelGpuResource] = "1"
+		}
+		if cont.HostConfig.NanoCPUs > 0 {
+			limits["cpu"] = fmt.Sprintf("%dm", cont.HostConfig.NanoCPUs/1e6)
+		}
+		if cont.HostConfig.Memory > 0 {
+			limits["memory"] = fmt.Sprint(cont.HostConfig.Memory)
+		}
+		if len(limits) > 0 {
+			k8sCont.Resources = &k8sResources{Limits: limits}
+		}
+		// Published ports are declared, a Service exposes them outside the pod
+		for port := range cont.HostConfig.PortBindings {
+			k8sCont.Ports = append(k8sCont.Ports, k8sContainerPort{ContainerPort: port.Int(), Protocol: strings.ToUpper(port.Proto())})
+		}
+		sort.Slice(k8sCont.Ports, func(i, j int) bool {
+			if k8sCont.Ports[i].ContainerPort != k8sCont.Ports[j].ContainerPort {
+				return k8sCont.Ports[i].ContainerPort < k8sCont.Ports[j].ContainerPort
+			}
+			return k8sCont.Ports[i].Protocol < k8sCont.Ports[j].Protocol
+		})
+		if cont.HostConfig.Privileged {
+			k8sCont.SecurityContext = &k8sSecurityContext{Privileged: true}
+		}
+		if cont.HostConfig.NetworkMode.IsHost() {
+			podSpec.HostNetwork = true
+		}
+		if cont.HostConfig.IpcMode.IsHost() {
+			podSpec.HostIPC = true
+		}
+		podSpec.Containers = append(podSpec.Containers, k8sCont)
+	}
+	if len(podSpec.Containers) == 0 {
+		return nil, fmt.Errorf("profile has no containers to export")
+	}
+	if secretVolume {
+		volume := k8sVolume{Name: secretName}
+		volume.Secret = &struct {
+			SecretName string `yaml:"secretName"`
+		}{SecretName: secretName}
+		podSpec.Volumes = append(podSpec.Volumes, volume)
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	for _, configMap := range configMaps {
+		if err := encoder.Encode(configMap); err != nil {
+			return nil, err
+		}
+	}
+	if kind == KubernetesPod {
+		// The launcher runs containers once, do the same for a bare Pod
+		podSpec.RestartPolicy = "Never"
+		pod := k8sPod{APIVersion: "v1", Kind: KubernetesPod, Metadata: k8sMetadata{Name: appName, Labels: labels}, Spec: podSpec}
+		if err := encoder.Encode(pod); err != nil {
+			return nil, err
+		}
+	} else {
+		deployment := k8sDeployment{APIVersion: "apps/v1", Kind: KubernetesDeployment, Metadata: k8sMetadata{Name: appName, Labels: labels}}
+		deployment.Spec.Replicas = 1
+		deployment.Spec.Selector.MatchLabels = labels
+		deployment.Spec.Template.Metadata = k8sMetadata{Labels: labels}
+		deployment.Spec.Template.Spec = podSpec
+		if err := encoder.Encode(deployment); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Decode a multi document manifest into generic objects
+func decodeManifests(t *testing.T, manifests []byte) []map[string]interface{} {
+	var objects []map[string]interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifests))
+	for {
+		object := map[string]interface{}{}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			return objects
+		}
+		require.NoError(t, err)
+		objects = append(objects, object)
+	}
+}
+
+// TestExportKubernetes: test generating Kubernetes manifests
+func TestExportKubernetes(t *testing.T) {
+	dns1123Label := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	tmpContainers := CreateTestContainers("/dev/video0", "GPU.0")
+	tmpContainers.SetHostNetwork()
+	tmpContainers.Containers[0].TargetDevice = "CPU"
+	tmpContainers.Containers[0].Envs = []string{"TEST_ENV=123", "INPUTSRC=/dev/video0", ""}
+	tmpContainers.Containers[0].HostConfig.Mounts = []mount.Mount{{Type: mount.TypeBind, Source: "/tmp/results", Target: "/tmp/results"}}
+	tmpContainers.Containers[0].HostConfig.Devices = []container.DeviceMapping{{PathOnHost: "/dev/video0", PathInContainer: "/dev/video0", CgroupPermissions: "rwm"}}
+	tmpContainers.Containers[1].HostConfig.Mounts = []mount.Mount{
+		{Type: mount.TypeBind, Source: "/tmp/results", Target: "/results", ReadOnly: true},
+		{Type: mount.TypeTmpfs, Target: "/scratch", TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 64 * 1024 * 1024}},
+	}
+	tmpContainers.Containers[1].HostConfig.Devices = []container.DeviceMapping{{PathOnHost: "/dev/dri/renderD128", PathInContainer: "/dev/dri/renderD128", CgroupPermissions: "rwm"}}
+	tmpContainers.Containers[1].Command = CommandArgs{"--port", "9000"}
+	tmpContainers.Containers[1].WorkingDir = "/models"
+	tmpContainers.Containers[1].HostConfig.NanoCPUs = 1500000000
+	tmpContainers.Containers[1].HostConfig.Memory = 512 * 1024 * 1024
+	tmpContainers.Containers[1].HostConfig.PortBindings = nat.PortMap{"9000/tcp": {{HostPort: "9000"}}, "5000/udp": {{}}}
+
+	tests := []struct {
+		name          string
+		kind          string
+		profileName   string
+		expectedErr   bool
+		expectedKinds []string
+	}{
+		{"valid pod", KubernetesPod, "valid_profile", false, []string{"ConfigMap", "ConfigMap", "Pod"}},
+		{"valid deployment", KubernetesDeployment, "valid_profile", false, []string{"ConfigMap", "ConfigMap", "Deployment"}},
+		{"invalid kind", "Job", "valid_profile", true, nil},
+		{"invalid profile name", KubernetesPod, "__", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasError := false
+			manifests, err := tmpContainers.ExportKubernetes(tt.profileName, tt.kind)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+
+			objects := decodeManifests(t, manifests)
+			var kinds []string
+			for _, object := range objects {
+				kinds = append(kinds, object["kind"].(string))
+				name := object["metadata"].(map[string]interface{})["name"].(string)
+				require.Regexp(t, dns1123Label, name)
+			}
+			require.Equal(t, tt.expectedKinds, kinds)
+			require.Equal(t, map[string]interface{}{"TEST_ENV": "123", "INPUTSRC": "/dev/video0"}, objects[0]["data"])
+
+			podSpec := objects[2]["spec"].(map[string]interface{})
+			if tt.kind == KubernetesDeployment {
+				podSpec = podSpec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+			}
+			require.Equal(t, true, podSpec["hostNetwork"])
+			// The results path is shared, the video device is mounted as a char device
+			// and the tmpfs is a memory emptyDir
+			volumes := podSpec["volumes"].([]interface{})
+			require.Len(t, volumes, 3)
+			require.Equal(t, map[string]interface{}{"medium": "Memory", "sizeLimit": "67108864"}, volumes[2].(map[string]interface{})["emptyDir"])
+			containers := podSpec["containers"].([]interface{})
+			require.Len(t, containers, 2)
+			client := containers[0].(map[string]interface{})
+			require.Equal(t, "client", client["name"])
+			require.Nil(t, client["resources"])
+			require.Len(t, client["volumeMounts"], 2)
+			server := containers[1].(map[string]interface{})
+			require.Equal(t, map[string]interface{}{"limits": map[string]interface{}{IntelGpuResource: "1", "cpu": "1500m", "memory": "536870912"}}, server["resources"])
+			require.Len(t, server["volumeMounts"], 2)
+			require.Equal(t, []interface{}{"--port", "9000"}, server["args"])
+			require.Equal(t, "/models", server["workingDir"])
+			require.Equal(t, []interface{}{
+				map[string]interface{}{"containerPort": 5000, "protocol": "UDP"},
+				map[string]interface{}{"containerPort": 9000, "protocol": "TCP"},
+			}, server["ports"])
+		})
+	}
+	// Render nodes mapped into a container not targeting a GPU don't request one
+	tmpContainers.Containers[1].TargetDevice = "CPU"
+	manifests, err := tmpContainers.ExportKubernetes("valid_profile", KubernetesPod)
+	require.NoError(t, err)
+	podSpec := decodeManifests(t, manifests)[2]["spec"].(map[string]interface{})
+	server := podSpec["containers"].([]interface{})[1].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"limits": map[string]interface{}{"cpu": "1500m", "memory": "536870912"}}, server["resources"])
+
+	// Named volumes have no Kubernetes equivalent
+	tmpContainers.Containers[1].HostConfig.Mounts = []mount.Mount{{Type: mount.TypeVolume, Source: "models", Target: "/models"}}
+	_, err = tmpContainers.ExportKubernetes("valid_profile", KubernetesPod)
+	require.Error(t, err)
+}
+
+// TestExportKubernetesSecrets: test secrets referencing a Secret instead of the ConfigMap
+func TestExportKubernetesSecrets(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0].Envs = []string{"TEST_ENV=123", "CAMERA_PASSWORD=k8s-pass-9"}
+	tmpContainers.Containers[0].Secrets = []ContainerSecret{{Name: "camera", Env: "CAMERA_PASSWORD"}, {Name: "token", File: "/run/secrets/token"}}
+
+	manifests, err := tmpContainers.ExportKubernetes("valid_profile", KubernetesPod)
+	require.NoError(t, err)
+	require.NotContains(t, string(manifests), "k8s-pass-9")
+	objects := decodeManifests(t, manifests)
+	require.Equal(t, map[string]interface{}{"TEST_ENV": "123"}, objects[0]["data"])
+
+	podSpec := objects[2]["spec"].(map[string]interface{})
+	client := podSpec["containers"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, []interface{}{map[string]interface{}{
+		"name":      "CAMERA_PASSWORD",
+		"valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "valid-profile-secrets", "key": "camera"}},
+	}}, client["env"])
+	require.Contains(t, client["volumeMounts"], map[string]interface{}{"name": "valid-profile-secrets", "mountPath": "/run/secrets/token", "subPath": "token", "readOnly": true})
+	require.Contains(t, podSpec["volumes"], map[string]interface{}{"name": "valid-profile-secrets", "secret": map[string]interface{}{"secretName": "valid-profile-secrets"}})
+
+	tmpContainers.Containers[0].Secrets = []ContainerSecret{{Name: "camera/password", Env: "CAMERA_PASSWORD"}}
+	_, err = tmpContainers.ExportKubernetes("valid_profile", KubernetesPod)
+	require.Error(t, err)
+}
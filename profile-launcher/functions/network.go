@@ -0,0 +1,173 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// Network modes of a profile
+const (
+	// User-defined bridge of the profile, containers resolve each other by name
+	NetworkModeBridge = "bridge"
+	// Host network and IPC namespace, the mode of profiles without a Network section
+	NetworkModeHost = "host"
+)
+
+// Label of the bridges created by the launcher, its value is the network name
+const networkProfileLabel = "profile-launcher.profile"
+
+// Network of the profile containers
+type NetworkConfig struct {
+	// bridge when empty, or host
+	Mode string `yaml:"Mode"`
+	// Bridge to create or reuse, defaults to profile-launcher-<profile directory>
+	Name string `yaml:"Name"`
+}
+
+// Default bridge name of the profile in configDir
+func ProfileNetworkName(configDir string) string {
+	if absConfigDir, err := filepath.Abs(configDir); err == nil {
+		configDir = absConfigDir
+	}
+	return "profile-launcher-" + KubernetesName(filepath.Base(configDir))
+}
+
+// Attach the containers to the profile network and publish their ports.
+// Profiles without a Network section keep using the host network.
+func (containerArray *Containers) SetNetwork(configDir string) error {
+	mode := NetworkModeHost
+	if containerArray.Network != nil {
+		mode = containerArray.Network.Mode
+		if mode == "" {
+			mode = NetworkModeBridge
+		}
+	}
+
+	switch mode {
+	case NetworkModeHost:
+		for _, cont := range containerArray.Containers {
+			if len(cont.Ports) > 0 || len(cont.Aliases) > 0 {
+				return fmt.Errorf("Container %v sets Ports or Aliases, they need a bridge Network", cont.Name)
+			}
+		}
+		containerArray.SetHostNetwork()
+		return nil
+	case NetworkModeBridge:
+	default:
+		return fmt.Errorf("Network mode %q must be %v or %v", mode, NetworkModeBridge, NetworkModeHost)
+	}
+
+	name := containerArray.Network.Name
+	if name == "" {
+		name = ProfileNetworkName(configDir)
+	}
+	// Docker's own networks don't resolve container names
+	if slices.Contains([]string{"bridge", "host", "none", "default"}, name) || !containerNamePattern.MatchString(name) {
+		return fmt.Errorf("Network name %q is not valid for a user-defined bridge", name)
+	}
+	containerArray.Network.Name = name
+
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		// A network mode set in the HostConfig such as host is kept
+		if cont.HostConfig.NetworkMode == "" {
+			cont.HostConfig.NetworkMode = container.NetworkMode(name)
+		} else if !cont.HostConfig.NetworkMode.IsUserDefined() && (len(cont.Ports) > 0 || len(cont.Aliases) > 0) {
+			return fmt.Errorf("Container %v sets Ports or Aliases, they need a bridge Network", cont.Name)
+		}
+		for _, alias := range cont.Aliases {
+			if !containerNamePattern.MatchString(alias) {
+				return fmt.Errorf("Container %v: alias %q is not a valid DNS name", cont.Name, alias)
+			}
+		}
+		_, bindings, err := nat.ParsePortSpecs(cont.Ports)
+		if err != nil {
+			return fmt.Errorf("Container %v: %v", cont.Name, err)
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+		if cont.HostConfig.PortBindings == nil {
+			cont.HostConfig.PortBindings = nat.PortMap{}
+		}
+		for port, portBindings := range bindings {
+			cont.HostConfig.PortBindings[port] = append(cont.HostConfig.PortBindings[port], portBindings...)
+		}
+	}
+	return nil
+}
+
+// Ports the container publishes, exposed in its Docker config
+func (cont *Container) ExposedPorts() nat.PortSet {
+	if len(cont.HostConfig.PortBindings) == 0 {
+		return nil
+	}
+	exposed := nat.PortSet{}
+	for port := range cont.HostConfig.PortBindings {
+		exposed[port] = struct{}{}
+	}
+	return exposed
+}
+
+// Endpoint of the container on its user-defined network with its DNS aliases
+func (cont *Container) NetworkingConfig() *network.NetworkingConfig {
+	if !cont.HostConfig.NetworkMode.IsUserDefined() || len(cont.Aliases) == 0 {
+		return nil
+	}
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			string(cont.HostConfig.NetworkMode): {Aliases: cont.Aliases},
+		},
+	}
+}
+
+// Create the user-defined networks of the containers, existing networks are reused
+func (containerArray *Containers) DockerCreateNetworks(ctx context.Context, cli *client.Client) error {
+	created := map[string]bool{}
+	for _, cont := range containerArray.Containers {
+		if !cont.HostConfig.NetworkMode.IsUserDefined() {
+			continue
+		}
+		name := string(cont.HostConfig.NetworkMode)
+		if created[name] {
+			continue
+		}
+		created[name] = true
+		if _, err := cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+			continue
+		} else if !client.IsErrNotFound(err) {
+			return fmt.Errorf("Failed to inspect network %v: %v", name, err)
+		}
+		fmt.Printf("Creating network %v\n", name)
+		options := types.NetworkCreate{Driver: "bridge", Labels: map[string]string{networkProfileLabel: name}}
+		if _, err := cli.NetworkCreate(ctx, name, options); err != nil {
+			return fmt.Errorf("Failed to create network %v: %v", name, err)
+		}
+	}
+	return nil
+}
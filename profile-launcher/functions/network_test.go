@@ -0,0 +1,120 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetNetwork: test host and bridge networks of a profile
+func TestSetNetwork(t *testing.T) {
+	tests := []struct {
+		name                 string
+		network              *NetworkConfig
+		ports                []string
+		aliases              []string
+		expectedErr          bool
+		expectedNetworkMode  container.NetworkMode
+		expectedIpcMode      container.IpcMode
+		expectedPortBindings nat.PortMap
+	}{
+		{"valid host without a network section", nil, nil, nil, false, "host", "host", nil},
+		{"valid explicit host", &NetworkConfig{Mode: NetworkModeHost}, nil, nil, false, "host", "host", nil},
+		{"valid default bridge name", &NetworkConfig{}, nil, nil, false, "profile-launcher-valid-profile", "", nil},
+		{"valid named bridge with ports", &NetworkConfig{Mode: NetworkModeBridge, Name: "demo"}, []string{"8080:80", "127.0.0.1:9000:9000/udp", "443"}, []string{"web"}, false, "demo", "", nat.PortMap{
+			"80/tcp":   {{HostPort: "8080"}},
+			"9000/udp": {{HostIP: "127.0.0.1", HostPort: "9000"}},
+			"443/tcp":  {{}},
+		}},
+		{"invalid ports on host", nil, []string{"8080:80"}, nil, true, "", "", nil},
+		{"invalid aliases on host", &NetworkConfig{Mode: NetworkModeHost}, nil, []string{"web"}, true, "", "", nil},
+		{"invalid mode", &NetworkConfig{Mode: "overlay"}, nil, nil, true, "", "", nil},
+		{"invalid docker network name", &NetworkConfig{Name: "bridge"}, nil, nil, true, "", "", nil},
+		{"invalid port", &NetworkConfig{}, []string{"80:http"}, nil, true, "", "", nil},
+		{"invalid alias", &NetworkConfig{}, nil, []string{"my web"}, true, "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", "")
+			tmpContainers.Network = tt.network
+			tmpContainers.Containers[1].Ports = tt.ports
+			tmpContainers.Containers[1].Aliases = tt.aliases
+
+			hasError := false
+			if err := tmpContainers.SetNetwork(testConfigDir); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+			for _, cont := range tmpContainers.Containers {
+				require.Equal(t, tt.expectedNetworkMode, cont.HostConfig.NetworkMode)
+				require.Equal(t, tt.expectedIpcMode, cont.HostConfig.IpcMode)
+			}
+			server := tmpContainers.Containers[1]
+			require.Equal(t, tt.expectedPortBindings, server.HostConfig.PortBindings)
+			if len(tt.expectedPortBindings) > 0 {
+				require.Len(t, server.DockerConfig().ExposedPorts, len(tt.expectedPortBindings))
+			} else {
+				require.Nil(t, server.DockerConfig().ExposedPorts)
+			}
+		})
+	}
+}
+
+// TestNetworkingConfig: test DNS aliases of containers on a bridge
+func TestNetworkingConfig(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Network = &NetworkConfig{Name: "demo"}
+	tmpContainers.Containers[0].Replicas = 2
+	tmpContainers.Containers[1].Aliases = []string{"inference"}
+	require.NoError(t, tmpContainers.SetNetwork(testConfigDir))
+	require.NoError(t, tmpContainers.ExpandReplicas())
+
+	require.Equal(t, &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{"demo": {Aliases: []string{"Client"}}}}, tmpContainers.Containers[1].NetworkingConfig())
+	require.Equal(t, &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{"demo": {Aliases: []string{"inference"}}}}, tmpContainers.Containers[2].NetworkingConfig())
+
+	// Containers on the host network have no endpoint settings
+	tmpContainers = CreateTestContainers("", "")
+	tmpContainers.SetHostNetwork()
+	require.Nil(t, tmpContainers.Containers[0].NetworkingConfig())
+
+	// A container can keep the host network of its HostConfig
+	tmpContainers = CreateTestContainers("", "")
+	tmpContainers.Network = &NetworkConfig{Name: "demo"}
+	tmpContainers.Containers[0].HostConfig.NetworkMode = "host"
+	require.NoError(t, tmpContainers.SetNetwork(testConfigDir))
+	require.Equal(t, container.NetworkMode("host"), tmpContainers.Containers[0].HostConfig.NetworkMode)
+	require.Equal(t, container.NetworkMode("demo"), tmpContainers.Containers[1].HostConfig.NetworkMode)
+	tmpContainers.Containers[0].Ports = []string{"8080:80"}
+	require.Error(t, tmpContainers.SetNetwork(testConfigDir))
+
+	// Replicas can't share a fixed host port
+	tmpContainers = CreateTestContainers("", "")
+	tmpContainers.Network = &NetworkConfig{}
+	tmpContainers.Containers[1].Ports = []string{"8080:80"}
+	tmpContainers.Containers[1].Replicas = 2
+	require.NoError(t, tmpContainers.SetNetwork(testConfigDir))
+	require.Error(t, tmpContainers.ExpandReplicas())
+}
@@ -0,0 +1,224 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+// Load a report written by a previous run
+func LoadRunReport(path string) (RunReport, error) {
+	report := RunReport{}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("Unable to read run report: %v, error: %v", path, err)
+	}
+	if err := json.Unmarshal(contents, &report); err != nil {
+		return report, fmt.Errorf("Run report %v: %v", path, err)
+	}
+	if len(report.Containers) == 0 {
+		return report, fmt.Errorf("Run report %v has no containers", path)
+	}
+	return report, nil
+}
+
+// Containers of a past run as they were launched, pinned to the image they
+// ran. Env values masked in the report are taken from envOverrides
+// (KEY=VALUE or Name:KEY=VALUE) or the host env. Secrets are resolved again
+// from the profile of the run. The warnings list what can't be reproduced.
+func (report *RunReport) ReplayContainers(envOverrides []string) (Containers, []string, error) {
+	// Overrides by container name, "" holds the ones of all containers
+	overrides := map[string]map[string]string{"": {}}
+	for _, envOverride := range envOverrides {
+		name, override, _ := cutContainerPrefix(envOverride, nil)
+		key, value, found := strings.Cut(override, "=")
+		if !found {
+			return Containers{}, nil, fmt.Errorf("env format incorrect, ensure env is EnvName=Value.")
+		}
+		if name != "" && !slices.ContainsFunc(report.Containers, func(runCont RunContainer) bool { return runCont.Name == name }) {
+			return Containers{}, nil, fmt.Errorf("Env %v: container %v not found in the run report", envOverride, name)
+		}
+		if overrides[name] == nil {
+			overrides[name] = map[string]string{}
+		}
+		overrides[name][key] = value
+	}
+
+	var warnings []string
+	containerArray := Containers{
+		Hooks:       report.Hooks,
+		HookContext: HookContext{ConfigDir: report.ConfigDir},
+	}
+	// The values of the secrets are not in the report, the profile says
+	// where to read them from again
+	var secretNames []string
+	for _, runCont := range report.Containers {
+		for _, contSecret := range runCont.Secrets {
+			if !slices.Contains(secretNames, contSecret.Name) {
+				secretNames = append(secretNames, contSecret.Name)
+			}
+		}
+	}
+	if len(secretNames) > 0 {
+		profile, err := GetYamlConfig(report.ConfigDir)
+		if err != nil {
+			return Containers{}, nil, fmt.Errorf("The run used secrets %v that can't be resolved again: %v", strings.Join(secretNames, ", "), err)
+		}
+		containerArray.Secrets = profile.Secrets
+		containerArray.SecretStore = profile.SecretStore
+	}
+
+	for _, runCont := range report.Containers {
+		cont := Container{
+			Name:          runCont.Name,
+			Replica:       runCont.Replica,
+			DockerImage:   runCont.Image,
+			Entrypoint:    runCont.Entrypoint,
+			Command:       runCont.Command,
+			WorkingDir:    runCont.WorkingDir,
+			User:          runCont.User,
+			Hostname:      runCont.Hostname,
+			Labels:        runCont.Labels,
+			StopSignal:    runCont.StopSignal,
+			StopTimeout:   runCont.StopTimeout,
+			PreStop:       runCont.PreStop,
+			Tty:           runCont.Tty,
+			DependsOn:     runCont.DependsOn,
+			Aliases:       runCont.Aliases,
+			RestartPolicy: runCont.RestartPolicy,
+			Healthcheck:   runCont.Healthcheck,
+			TargetDevice:  runCont.TargetDevice,
+			HostConfig:    runCont.HostConfig,
+			Secrets:       slices.Clone(runCont.Secrets),
+		}
+		// Secret files of the run were removed with its containers, they
+		// are written again when the containers start
+		if report.SecretDir != "" {
+			cont.HostConfig.Mounts = slices.DeleteFunc(slices.Clone(cont.HostConfig.Mounts), func(mnt mount.Mount) bool {
+				return strings.HasPrefix(mnt.Source, report.SecretDir+string(filepath.Separator))
+			})
+		}
+		secretEnvs := map[string]bool{}
+		for _, contSecret := range cont.Secrets {
+			if contSecret.Env != "" {
+				secretEnvs[contSecret.Env] = true
+			}
+		}
+		if runCont.ImageID != "" {
+			cont.DockerImage = runCont.ImageID
+		} else {
+			warnings = append(warnings, fmt.Sprintf("Container %v: no image digest recorded, %v may have changed since the run", runCont.Name, runCont.Image))
+		}
+
+		for _, env := range runCont.Envs {
+			key, value, _ := strings.Cut(env, "=")
+			if override, ok := overrides[runCont.Name][key]; ok {
+				env = key + "=" + override
+			} else if override, ok := overrides[""][key]; ok {
+				env = key + "=" + override
+			} else if secretEnvs[key] {
+				// Set once the secret is resolved
+			} else if strings.Contains(value, maskedValue) {
+				hostValue, ok := os.LookupEnv(key)
+				if !ok {
+					return Containers{}, nil, fmt.Errorf("Container %v: env %v was masked in the run report, pass it with -e %v=value or set it in the environment", runCont.Name, key, key)
+				}
+				env = key + "=" + hostValue
+			}
+			cont.Envs = append(cont.Envs, env)
+		}
+
+		for _, device := range cont.HostConfig.Devices {
+			if _, err := statDeviceNode(device.PathOnHost); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Container %v: host device %v is missing", runCont.Name, device.PathOnHost))
+			}
+		}
+		for _, mnt := range cont.HostConfig.Mounts {
+			if mnt.Type != mount.TypeBind {
+				continue
+			}
+			if _, err := os.Stat(mnt.Source); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Container %v: mount source %v is missing", runCont.Name, mnt.Source))
+			}
+		}
+		for _, bind := range cont.HostConfig.Binds {
+			spec, err := ParseVolume(bind)
+			if err != nil || spec.Type != mount.TypeBind {
+				continue
+			}
+			if _, err := os.Stat(spec.Source); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Container %v: mount source %v is missing", runCont.Name, spec.Source))
+			}
+		}
+		containerArray.Containers = append(containerArray.Containers, cont)
+	}
+	// A supervised run recorded no Docker restart policy
+	if err := containerArray.SetRestartPolicies(); err != nil {
+		return Containers{}, nil, err
+	}
+	if err := containerArray.SetSecrets(report.ConfigDir); err != nil {
+		return Containers{}, nil, fmt.Errorf("The run used secrets %v that can't be resolved again: %v", strings.Join(secretNames, ", "), err)
+	}
+	return containerArray, warnings, nil
+}
+
+// Check the pinned images are still available, pulling them by repo digest
+// when they were removed since the run
+func (report *RunReport) PullReplayImages(ctx context.Context, cli *client.Client, containerArray *Containers) ([]string, error) {
+	var warnings []string
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		runCont := report.Containers[contIndex]
+		if runCont.ImageID == "" {
+			continue
+		}
+		if _, _, err := cli.ImageInspectWithRaw(ctx, runCont.ImageID); err == nil {
+			continue
+		} else if !client.IsErrNotFound(err) {
+			return warnings, fmt.Errorf("Failed to inspect image %v: %v", runCont.Image, err)
+		}
+		if len(runCont.RepoDigests) == 0 {
+			return warnings, fmt.Errorf("Container %v: image %v (%v) is no longer available locally and has no repo digest to pull it by", cont.Name, runCont.Image, runCont.ImageID)
+		}
+
+		pinned := runCont.RepoDigests[0]
+		warnings = append(warnings, fmt.Sprintf("Container %v: image %v is no longer available locally, pulling %v", cont.Name, runCont.Image, pinned))
+		progress, err := cli.ImagePull(ctx, pinned, types.ImagePullOptions{})
+		if err != nil {
+			return warnings, fmt.Errorf("Failed to pull image %v: %v", pinned, err)
+		}
+		_, err = io.Copy(io.Discard, progress)
+		progress.Close()
+		if err != nil {
+			return warnings, fmt.Errorf("Failed to pull image %v: %v", pinned, err)
+		}
+		cont.DockerImage = pinned
+	}
+	return warnings, nil
+}
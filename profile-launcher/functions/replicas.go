@@ -0,0 +1,109 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Replace each container with Replicas set by that many copies. A single
+// replica keeps the container name, more replicas are named <Name>-<n>.
+// Dependencies on a replicated container wait for all of its replicas.
+func (containerArray *Containers) ExpandReplicas() error {
+	replicaNames := map[string][]string{}
+	for _, cont := range containerArray.Containers {
+		if cont.Replicas < 0 {
+			return fmt.Errorf("Container %v: Replicas %v must be positive", cont.Name, cont.Replicas)
+		}
+		if cont.Replicas <= 1 {
+			continue
+		}
+		// Replicas can't all bind the same host port
+		for port, bindings := range cont.HostConfig.PortBindings {
+			for _, binding := range bindings {
+				if binding.HostPort != "" {
+					return fmt.Errorf("Container %v: replicas can't all publish host port %v for %v, leave the host port out to pick free ones", cont.Name, binding.HostPort, port)
+				}
+			}
+		}
+		for replica := 1; replica <= cont.Replicas; replica++ {
+			replicaNames[cont.Name] = append(replicaNames[cont.Name], fmt.Sprintf("%v-%d", cont.Name, replica))
+		}
+	}
+
+	var expanded []Container
+	names := map[string]bool{}
+	for _, cont := range containerArray.Containers {
+		copies := max(cont.Replicas, 1)
+		for replica := 1; replica <= copies; replica++ {
+			replicaCont := cont.clone()
+			if cont.Replicas > 0 {
+				replicaCont.Replica = replica
+			}
+			if cont.Replicas > 1 {
+				replicaCont.Name = replicaNames[cont.Name][replica-1]
+				// On a bridge the container name resolves to every replica
+				replicaCont.Aliases = append(replicaCont.Aliases, cont.Name)
+			}
+			replicaCont.DependsOn = nil
+			for _, dependency := range cont.DependsOn {
+				if dependencyReplicas, ok := replicaNames[dependency]; ok {
+					replicaCont.DependsOn = append(replicaCont.DependsOn, dependencyReplicas...)
+				} else {
+					replicaCont.DependsOn = append(replicaCont.DependsOn, dependency)
+				}
+			}
+			if names[replicaCont.Name] {
+				return fmt.Errorf("Replica %v of container %v has the name of another container", replicaCont.Name, cont.Name)
+			}
+			names[replicaCont.Name] = true
+			expanded = append(expanded, replicaCont)
+		}
+	}
+	containerArray.Containers = expanded
+	return nil
+}
+
+// Copy of the container that shares no slices or maps with it
+func (cont Container) clone() Container {
+	cont.Envs = slices.Clone(cont.Envs)
+	cont.Volumes = slices.Clone(cont.Volumes)
+	cont.Entrypoint = slices.Clone(cont.Entrypoint)
+	cont.Command = slices.Clone(cont.Command)
+	cont.PreStop = slices.Clone(cont.PreStop)
+	cont.Labels = maps.Clone(cont.Labels)
+	cont.Secrets = slices.Clone(cont.Secrets)
+	cont.Ports = slices.Clone(cont.Ports)
+	cont.Aliases = slices.Clone(cont.Aliases)
+	if cont.InputSrc != nil {
+		inputSrc := *cont.InputSrc
+		cont.InputSrc = &inputSrc
+	}
+	hostConfig := &cont.HostConfig
+	hostConfig.Binds = slices.Clone(hostConfig.Binds)
+	hostConfig.Mounts = slices.Clone(hostConfig.Mounts)
+	hostConfig.Devices = slices.Clone(hostConfig.Devices)
+	hostConfig.DeviceCgroupRules = slices.Clone(hostConfig.DeviceCgroupRules)
+	hostConfig.GroupAdd = slices.Clone(hostConfig.GroupAdd)
+	hostConfig.Ulimits = slices.Clone(hostConfig.Ulimits)
+	hostConfig.Tmpfs = maps.Clone(hostConfig.Tmpfs)
+	hostConfig.PortBindings = maps.Clone(hostConfig.PortBindings)
+	return cont
+}
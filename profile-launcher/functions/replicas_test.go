@@ -0,0 +1,89 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpandReplicas: test copying containers into replicas
+func TestExpandReplicas(t *testing.T) {
+	tests := []struct {
+		name              string
+		clientReplicas    int
+		serverReplicas    int
+		expectedErr       bool
+		expectedNames     []string
+		expectedDependsOn [][]string
+		expectedReplicas  []int
+	}{
+		{"valid no replicas", 0, 0, false, []string{"Client", "Server"}, [][]string{nil, {"Client"}}, []int{0, 0}},
+		{"valid single replica keeps the name", 1, 0, false, []string{"Client", "Server"}, [][]string{nil, {"Client"}}, []int{1, 0}},
+		{"valid dependency on all replicas", 2, 0, false, []string{"Client-1", "Client-2", "Server"}, [][]string{nil, nil, {"Client-1", "Client-2"}}, []int{1, 2, 0}},
+		{"valid both replicated", 2, 3, false, []string{"Client-1", "Client-2", "Server-1", "Server-2", "Server-3"}, [][]string{nil, nil, {"Client-1", "Client-2"}, {"Client-1", "Client-2"}, {"Client-1", "Client-2"}}, []int{1, 2, 1, 2, 3}},
+		{"invalid negative replicas", -1, 0, true, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", "")
+			tmpContainers.Containers[0].Replicas = tt.clientReplicas
+			tmpContainers.Containers[1].Replicas = tt.serverReplicas
+			tmpContainers.Containers[1].DependsOn = []string{"Client"}
+
+			hasError := false
+			if err := tmpContainers.ExpandReplicas(); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+			var names []string
+			var dependsOn [][]string
+			var replicas []int
+			for _, cont := range tmpContainers.Containers {
+				names = append(names, cont.Name)
+				dependsOn = append(dependsOn, cont.DependsOn)
+				replicas = append(replicas, cont.Replica)
+			}
+			require.Equal(t, tt.expectedNames, names)
+			require.Equal(t, tt.expectedDependsOn, dependsOn)
+			require.Equal(t, tt.expectedReplicas, replicas)
+		})
+	}
+
+	// Replicas share nothing with each other
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0].Envs = make([]string, 1, 4)
+	tmpContainers.Containers[0].HostConfig.Mounts = make([]mount.Mount, 0, 4)
+	tmpContainers.Containers[0].Replicas = 2
+	require.NoError(t, tmpContainers.ExpandReplicas())
+	tmpContainers.Containers[0].SetEnv("REPLICA", "1")
+	tmpContainers.Containers[0].HostConfig.Mounts = append(tmpContainers.Containers[0].HostConfig.Mounts, mount.Mount{Target: "/one"})
+	require.Equal(t, []string{""}, tmpContainers.Containers[1].Envs)
+	require.Empty(t, tmpContainers.Containers[1].HostConfig.Mounts)
+
+	// A replica can't take the name of another container
+	tmpContainers = CreateTestContainers("", "")
+	tmpContainers.Containers[1].Name = "Client-2"
+	tmpContainers.Containers[0].Replicas = 2
+	require.Error(t, tmpContainers.ExpandReplicas())
+}
@@ -0,0 +1,208 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// Resource limits of a container, sizes take units such as 512m or 4g.
+// Set fields replace the matching HostConfig fields.
+type Resources struct {
+	// Number of CPUs, fractions such as 1.5 are allowed
+	Cpus float64 `yaml:"Cpus,omitempty"`
+	// CPUs the container may run on such as 0-3,8
+	Cpuset string `yaml:"Cpuset,omitempty"`
+	// NUMA nodes the container may allocate memory on
+	CpusetMems string `yaml:"CpusetMems,omitempty"`
+	Memory     string `yaml:"Memory,omitempty"`
+	// Memory plus swap, -1 for unlimited swap
+	MemorySwap string `yaml:"MemorySwap,omitempty"`
+	ShmSize    string `yaml:"ShmSize,omitempty"`
+	// Most processes in the container, -1 for unlimited
+	PidsLimit *int64 `yaml:"PidsLimit,omitempty"`
+	// Ulimits by name, a single value sets soft and hard, soft:hard sets both
+	Ulimits map[string]string `yaml:"Ulimits,omitempty"`
+}
+
+// Resources are validated when the profile is loaded
+func (resources *Resources) UnmarshalYAML(value *yaml.Node) error {
+	type plainResources Resources
+	if err := value.Decode((*plainResources)(resources)); err != nil {
+		return err
+	}
+	if err := resources.AddTo(&container.HostConfig{}); err != nil {
+		return fmt.Errorf("line %v: %v", value.Line, err)
+	}
+	return nil
+}
+
+// Set the resource limits in the host config
+func (resources Resources) AddTo(hostConfig *container.HostConfig) error {
+	if resources.Cpus < 0 {
+		return fmt.Errorf("Cpus %v must be positive", resources.Cpus)
+	} else if resources.Cpus > 0 {
+		hostConfig.NanoCPUs = int64(resources.Cpus * 1e9)
+	}
+	if resources.Cpuset != "" {
+		if _, err := ParseCPUList(resources.Cpuset); err != nil {
+			return fmt.Errorf("Cpuset %v", err)
+		}
+		hostConfig.CpusetCpus = resources.Cpuset
+	}
+	if resources.CpusetMems != "" {
+		if _, err := ParseCPUList(resources.CpusetMems); err != nil {
+			return fmt.Errorf("CpusetMems %v", err)
+		}
+		hostConfig.CpusetMems = resources.CpusetMems
+	}
+
+	if resources.Memory != "" {
+		memory, err := units.RAMInBytes(resources.Memory)
+		if err != nil || memory <= 0 {
+			return fmt.Errorf("Memory %q is not a valid size", resources.Memory)
+		}
+		hostConfig.Memory = memory
+	}
+	if resources.MemorySwap != "" {
+		swap := int64(-1)
+		if resources.MemorySwap != "-1" {
+			var err error
+			swap, err = units.RAMInBytes(resources.MemorySwap)
+			if err != nil || swap <= 0 {
+				return fmt.Errorf("MemorySwap %q is not a valid size", resources.MemorySwap)
+			}
+		}
+		// Docker only takes the swap limit together with a memory limit
+		if hostConfig.Memory == 0 {
+			return fmt.Errorf("MemorySwap needs Memory to be set")
+		}
+		if swap != -1 && swap < hostConfig.Memory {
+			return fmt.Errorf("MemorySwap %v must not be less than Memory %v", resources.MemorySwap, resources.Memory)
+		}
+		hostConfig.MemorySwap = swap
+	}
+	if resources.ShmSize != "" {
+		shmSize, err := units.RAMInBytes(resources.ShmSize)
+		if err != nil || shmSize <= 0 {
+			return fmt.Errorf("ShmSize %q is not a valid size", resources.ShmSize)
+		}
+		hostConfig.ShmSize = shmSize
+	}
+	if resources.PidsLimit != nil {
+		if *resources.PidsLimit == 0 || *resources.PidsLimit < -1 {
+			return fmt.Errorf("PidsLimit %v must be positive or -1", *resources.PidsLimit)
+		}
+		pidsLimit := *resources.PidsLimit
+		hostConfig.PidsLimit = &pidsLimit
+	}
+
+	// Sorted so the host config doesn't depend on the map order
+	var names []string
+	for name := range resources.Ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ulimit, err := units.ParseUlimit(name + "=" + resources.Ulimits[name])
+		if err != nil {
+			return fmt.Errorf("Ulimit %v", err)
+		}
+		hostConfig.Ulimits = append(removeUlimit(hostConfig.Ulimits, name), ulimit)
+	}
+	return nil
+}
+
+func removeUlimit(ulimits []*units.Ulimit, name string) []*units.Ulimit {
+	var kept []*units.Ulimit
+	for _, ulimit := range ulimits {
+		if ulimit.Name != name {
+			kept = append(kept, ulimit)
+		}
+	}
+	return kept
+}
+
+// Apply the Resources of each container to its host config
+func (containerArray *Containers) SetResources() error {
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		if cont.Resources == nil {
+			continue
+		}
+		if err := cont.Resources.AddTo(&cont.HostConfig); err != nil {
+			return fmt.Errorf("Container %v: %v", cont.Name, err)
+		}
+	}
+	return nil
+}
+
+// Parse a kernel CPU list such as 0-3,8,10-11 into sorted CPU numbers
+func ParseCPUList(cpuList string) ([]int, error) {
+	cpus := map[int]bool{}
+	for _, item := range strings.Split(strings.TrimSpace(cpuList), ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("%q is not a valid CPU list", cpuList)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("%q is not a valid CPU list", cpuList)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	var sorted []int
+	for cpu := range cpus {
+		sorted = append(sorted, cpu)
+	}
+	sort.Ints(sorted)
+	return sorted, nil
+}
+
+// Format CPU numbers as a kernel CPU list, runs become ranges
+func FormatCPUList(cpus []int) string {
+	sorted := append([]int{}, cpus...)
+	sort.Ints(sorted)
+	var items []string
+	for start := 0; start < len(sorted); {
+		end := start
+		for end+1 < len(sorted) && sorted[end+1] <= sorted[end]+1 {
+			end++
+		}
+		if sorted[end] == sorted[start] {
+			items = append(items, strconv.Itoa(sorted[start]))
+		} else {
+			items = append(items, fmt.Sprintf("%d-%d", sorted[start], sorted[end]))
+		}
+		start = end + 1
+	}
+	return strings.Join(items, ",")
+}
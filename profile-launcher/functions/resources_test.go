@@ -0,0 +1,110 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestResourcesAddTo: test setting resource limits in the host config
+func TestResourcesAddTo(t *testing.T) {
+	pidsLimit := int64(256)
+	invalidPidsLimit := int64(0)
+	tests := []struct {
+		name               string
+		resources          Resources
+		expectedErr        bool
+		expectedHostConfig container.HostConfig
+	}{
+		{"valid cpus and cpuset", Resources{Cpus: 1.5, Cpuset: "0-3,8", CpusetMems: "0"}, false, container.HostConfig{Resources: container.Resources{NanoCPUs: 1500000000, CpusetCpus: "0-3,8", CpusetMems: "0"}}},
+		{"valid memory and swap", Resources{Memory: "512m", MemorySwap: "1g"}, false, container.HostConfig{Resources: container.Resources{Memory: 512 * 1024 * 1024, MemorySwap: 1024 * 1024 * 1024}}},
+		{"valid unlimited swap", Resources{Memory: "512m", MemorySwap: "-1"}, false, container.HostConfig{Resources: container.Resources{Memory: 512 * 1024 * 1024, MemorySwap: -1}}},
+		{"valid shm and pids", Resources{ShmSize: "2g", PidsLimit: &pidsLimit}, false, container.HostConfig{ShmSize: 2 * 1024 * 1024 * 1024, Resources: container.Resources{PidsLimit: &pidsLimit}}},
+		{"valid ulimits", Resources{Ulimits: map[string]string{"nofile": "1024:4096", "memlock": "-1"}}, false, container.HostConfig{Resources: container.Resources{Ulimits: []*units.Ulimit{{Name: "memlock", Soft: -1, Hard: -1}, {Name: "nofile", Soft: 1024, Hard: 4096}}}}},
+		{"invalid negative cpus", Resources{Cpus: -1}, true, container.HostConfig{}},
+		{"invalid cpuset", Resources{Cpuset: "3-1"}, true, container.HostConfig{}},
+		{"invalid memory", Resources{Memory: "lots"}, true, container.HostConfig{}},
+		{"invalid swap without memory", Resources{MemorySwap: "1g"}, true, container.HostConfig{}},
+		{"invalid swap below memory", Resources{Memory: "1g", MemorySwap: "512m"}, true, container.HostConfig{}},
+		{"invalid pids limit", Resources{PidsLimit: &invalidPidsLimit}, true, container.HostConfig{}},
+		{"invalid ulimit name", Resources{Ulimits: map[string]string{"files": "1024"}}, true, container.HostConfig{}},
+		{"invalid ulimit soft above hard", Resources{Ulimits: map[string]string{"nofile": "4096:1024"}}, true, container.HostConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostConfig := container.HostConfig{}
+			hasError := false
+			if err := tt.resources.AddTo(&hostConfig); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedHostConfig, hostConfig)
+			}
+		})
+	}
+}
+
+// TestSetResources: test resources replacing HostConfig fields and invalid profiles
+func TestSetResources(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0].HostConfig.ShmSize = 64 * 1024 * 1024
+	tmpContainers.Containers[0].HostConfig.Ulimits = []*units.Ulimit{{Name: "nofile", Soft: 1, Hard: 1}, {Name: "core", Soft: 0, Hard: 0}}
+	tmpContainers.Containers[0].Resources = &Resources{ShmSize: "1g", Ulimits: map[string]string{"nofile": "65536"}}
+	require.NoError(t, tmpContainers.SetResources())
+	require.Equal(t, int64(1024*1024*1024), tmpContainers.Containers[0].HostConfig.ShmSize)
+	require.Equal(t, []*units.Ulimit{{Name: "core", Soft: 0, Hard: 0}, {Name: "nofile", Soft: 65536, Hard: 65536}}, tmpContainers.Containers[0].HostConfig.Ulimits)
+
+	resources := Resources{}
+	require.NoError(t, yaml.Unmarshal([]byte("Cpus: 2\nMemory: 4g\nUlimits:\n  nofile: 1024\n"), &resources))
+	require.Equal(t, Resources{Cpus: 2, Memory: "4g", Ulimits: map[string]string{"nofile": "1024"}}, resources)
+	require.Error(t, yaml.Unmarshal([]byte("Memory: 4 gigs\n"), &Resources{}))
+}
+
+// TestCPUList: test parsing and formatting kernel CPU lists
+func TestCPUList(t *testing.T) {
+	tests := []struct {
+		name         string
+		cpuList      string
+		expectedErr  bool
+		expectedCpus []int
+		expectedList string
+	}{
+		{"valid single", "3", false, []int{3}, "3"},
+		{"valid ranges", "0-3,8,10-11\n", false, []int{0, 1, 2, 3, 8, 10, 11}, "0-3,8,10-11"},
+		{"valid unsorted overlapping", "4,0-2,1", false, []int{0, 1, 2, 4}, "0-2,4"},
+		{"invalid empty", "", true, nil, ""},
+		{"invalid reversed range", "3-1", true, nil, ""},
+		{"invalid word", "all", true, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpus, err := ParseCPUList(tt.cpuList)
+			require.Equal(t, tt.expectedErr, err != nil)
+			require.Equal(t, tt.expectedCpus, cpus)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedList, FormatCPUList(cpus))
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Paths of the local secret store, values are encrypted with AES-256-GCM
+// using the key in KeyFile
+type SecretStoreConfig struct {
+	File    string `yaml:"File"`
+	KeyFile string `yaml:"KeyFile"`
+}
+
+// Store file contents, the values are base64 of nonce then ciphertext
+type secretStoreFile struct {
+	Secrets map[string]string `json:"secrets"`
+}
+
+// Local store of encrypted secrets
+type SecretStore struct {
+	path    string
+	aead    cipher.AEAD
+	secrets map[string]string
+}
+
+// Store in the user config directory, used when the profile sets no SecretStore
+func DefaultSecretStoreConfig() SecretStoreConfig {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	storeDir := filepath.Join(configDir, "profile-launcher")
+	return SecretStoreConfig{
+		File:    filepath.Join(storeDir, "secrets.json"),
+		KeyFile: filepath.Join(storeDir, "secrets.key"),
+	}
+}
+
+// Open the store, with create a missing key file is generated and a
+// missing store starts empty
+func OpenSecretStore(config SecretStoreConfig, create bool) (*SecretStore, error) {
+	key, err := readSecretKey(config.KeyFile)
+	if errors.Is(err, os.ErrNotExist) && create {
+		key, err = writeSecretKey(config.KeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Secret key %v: %v", config.KeyFile, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &SecretStore{path: config.File, aead: aead, secrets: map[string]string{}}
+	contents, err := os.ReadFile(config.File)
+	if errors.Is(err, os.ErrNotExist) && create {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read secret store: %v, error: %v", config.File, err)
+	}
+	storeFile := secretStoreFile{}
+	if err := json.Unmarshal(contents, &storeFile); err != nil {
+		return nil, fmt.Errorf("Secret store %v: %v", config.File, err)
+	}
+	if storeFile.Secrets != nil {
+		store.secrets = storeFile.Secrets
+	}
+	return store, nil
+}
+
+func readSecretKey(keyFile string) ([]byte, error) {
+	contents, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("Secret key %v must hold 32 hex encoded bytes", keyFile)
+	}
+	return key, nil
+}
+
+func writeSecretKey(keyFile string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, fmt.Errorf("Failed to create secret key directory %v", err)
+	}
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("Failed to write secret key %v", err)
+	}
+	return key, nil
+}
+
+// Decrypted value of a secret, the name is authenticated with the value so
+// an entry can't be moved to another name
+func (store *SecretStore) Get(name string) (string, error) {
+	encoded, ok := store.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %v not found in the secret store %v", name, store.path)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	nonceSize := store.aead.NonceSize()
+	if err != nil || len(sealed) < nonceSize {
+		return "", fmt.Errorf("secret %v in the secret store %v is corrupted", name, store.path)
+	}
+	value, err := store.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("secret %v can't be decrypted with the secret key", name)
+	}
+	return string(value), nil
+}
+
+// Encrypt and set the value of a secret, Save writes it
+func (store *SecretStore) Set(name string, value string) error {
+	nonce := make([]byte, store.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := store.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	store.secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+	return nil
+}
+
+// Remove a secret, Save writes it
+func (store *SecretStore) Remove(name string) error {
+	if _, ok := store.secrets[name]; !ok {
+		return fmt.Errorf("secret %v not found in the secret store %v", name, store.path)
+	}
+	delete(store.secrets, name)
+	return nil
+}
+
+// Names of the stored secrets
+func (store *SecretStore) Names() []string {
+	var names []string
+	for name := range store.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write the store readable by the user only
+func (store *SecretStore) Save() error {
+	contents, err := json.MarshalIndent(secretStoreFile{Secrets: store.secrets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store.path), 0700); err != nil {
+		return fmt.Errorf("Failed to create secret store directory %v", err)
+	}
+	return os.WriteFile(store.path, append(contents, '\n'), 0600)
+}
@@ -0,0 +1,101 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Secret store in a temporary directory holding the given secrets
+func createTestSecretStore(t *testing.T, secrets map[string]string) SecretStoreConfig {
+	storeDir := t.TempDir()
+	config := SecretStoreConfig{File: filepath.Join(storeDir, "secrets.json"), KeyFile: filepath.Join(storeDir, "secrets.key")}
+	store, err := OpenSecretStore(config, true)
+	require.NoError(t, err)
+	for name, value := range secrets {
+		require.NoError(t, store.Set(name, value))
+	}
+	require.NoError(t, store.Save())
+	return config
+}
+
+// TestSecretStore: test storing and reading encrypted secrets
+func TestSecretStore(t *testing.T) {
+	config := createTestSecretStore(t, map[string]string{"rtsp_password": "store-pass-1", "registry_token": "store-token-2"})
+
+	keyInfo, err := os.Stat(config.KeyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), keyInfo.Mode().Perm())
+	contents, err := os.ReadFile(config.File)
+	require.NoError(t, err)
+	require.NotContains(t, string(contents), "store-pass-1")
+
+	store, err := OpenSecretStore(config, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"registry_token", "rtsp_password"}, store.Names())
+	value, err := store.Get("rtsp_password")
+	require.NoError(t, err)
+	require.Equal(t, "store-pass-1", value)
+	_, err = store.Get("missing")
+	require.Error(t, err)
+
+	require.NoError(t, store.Remove("registry_token"))
+	require.Error(t, store.Remove("registry_token"))
+	require.NoError(t, store.Save())
+	store, err = OpenSecretStore(config, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"rtsp_password"}, store.Names())
+}
+
+// TestSecretStoreErrors: test stores that can't be read
+func TestSecretStoreErrors(t *testing.T) {
+	config := createTestSecretStore(t, map[string]string{"rtsp_password": "store-pass-1", "other": "store-other-3"})
+
+	// An entry moved to another name doesn't decrypt
+	contents, err := os.ReadFile(config.File)
+	require.NoError(t, err)
+	storeFile := secretStoreFile{}
+	require.NoError(t, json.Unmarshal(contents, &storeFile))
+	storeFile.Secrets["other"] = storeFile.Secrets["rtsp_password"]
+	contents, err = json.Marshal(storeFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(config.File, contents, 0600))
+	store, err := OpenSecretStore(config, false)
+	require.NoError(t, err)
+	_, err = store.Get("other")
+	require.Error(t, err)
+
+	// Another key doesn't decrypt
+	otherConfig := createTestSecretStore(t, nil)
+	store, err = OpenSecretStore(SecretStoreConfig{File: config.File, KeyFile: otherConfig.KeyFile}, false)
+	require.NoError(t, err)
+	_, err = store.Get("rtsp_password")
+	require.Error(t, err)
+
+	// Missing files are only created when asked
+	_, err = OpenSecretStore(SecretStoreConfig{File: config.File, KeyFile: filepath.Join(t.TempDir(), "missing.key")}, false)
+	require.Error(t, err)
+	require.NoError(t, os.WriteFile(config.KeyFile, []byte("not a key"), 0600))
+	_, err = OpenSecretStore(config, false)
+	require.Error(t, err)
+}
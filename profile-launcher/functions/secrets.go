@@ -0,0 +1,244 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Directory secret files are written to before they are mounted, a tmpfs
+// so they never reach the disk
+var SecretFileRoot = "/dev/shm"
+
+// Directory secret files of a container are mounted in by default
+const SecretMountDir = "/run/secrets"
+
+// Secret of the profile, its value comes from exactly one of a file, a
+// host env or the local secret store
+type Secret struct {
+	Name  string `yaml:"Name"`
+	File  string `yaml:"File"`
+	Env   string `yaml:"Env"`
+	Store string `yaml:"Store"`
+}
+
+// Secret given to a container as an env, a read-only file or both. Without
+// either it is mounted at /run/secrets/<Name>.
+type ContainerSecret struct {
+	Name  string `yaml:"Name" json:"name"`
+	Env   string `yaml:"Env" json:"env,omitempty"`
+	File  string `yaml:"File" json:"file,omitempty"`
+	value string
+}
+
+var (
+	secretValuesLock sync.Mutex
+	// Values of the resolved secrets, masked in all output
+	secretValues = map[string]bool{}
+)
+
+// Mask a value in all launcher output from now on
+func RegisterSecretValue(value string) {
+	value = strings.TrimRight(value, "\r\n")
+	if value == "" {
+		return
+	}
+	secretValuesLock.Lock()
+	defer secretValuesLock.Unlock()
+	secretValues[value] = true
+}
+
+// Text with the values of the resolved secrets masked
+func MaskSecrets(text string) string {
+	secretValuesLock.Lock()
+	values := make([]string, 0, len(secretValues))
+	for value := range secretValues {
+		values = append(values, value)
+	}
+	secretValuesLock.Unlock()
+	// Longest first so a secret containing another is masked whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, maskedValue)
+	}
+	return text
+}
+
+// Value of the secret, relative files are read from the profile directory
+func (secret Secret) Value(configDir string, openStore func() (*SecretStore, error)) (string, error) {
+	sources := 0
+	for _, source := range []string{secret.File, secret.Env, secret.Store} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return "", fmt.Errorf("secret %v must set exactly one of File, Env or Store", secret.Name)
+	}
+
+	switch {
+	case secret.File != "":
+		secretPath := secret.File
+		if !filepath.IsAbs(secretPath) {
+			secretPath = filepath.Join(configDir, secretPath)
+		}
+		contents, err := os.ReadFile(secretPath)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read secret %v: %v", secret.Name, err)
+		}
+		return string(contents), nil
+	case secret.Env != "":
+		value, ok := os.LookupEnv(secret.Env)
+		if !ok {
+			return "", fmt.Errorf("secret %v: env %v is not set in the host environment", secret.Name, secret.Env)
+		}
+		return value, nil
+	}
+	store, err := openStore()
+	if err != nil {
+		return "", fmt.Errorf("secret %v: %v", secret.Name, err)
+	}
+	return store.Get(secret.Store)
+}
+
+// Resolve the secrets the containers use, set their envs and register the
+// values for masking. Secret files are written when the containers start.
+func (containerArray *Containers) SetSecrets(configDir string) error {
+	secrets := map[string]Secret{}
+	for _, secret := range containerArray.Secrets {
+		if !containerNamePattern.MatchString(secret.Name) {
+			return fmt.Errorf("secret name %q is not valid", secret.Name)
+		}
+		if _, ok := secrets[secret.Name]; ok {
+			return fmt.Errorf("secret %v is declared twice", secret.Name)
+		}
+		secrets[secret.Name] = secret
+	}
+
+	var store *SecretStore
+	openStore := func() (*SecretStore, error) {
+		if store != nil {
+			return store, nil
+		}
+		config := DefaultSecretStoreConfig()
+		if containerArray.SecretStore != nil {
+			config = *containerArray.SecretStore
+			for _, storePath := range []*string{&config.File, &config.KeyFile} {
+				if *storePath != "" && !filepath.IsAbs(*storePath) {
+					*storePath = filepath.Join(configDir, *storePath)
+				}
+			}
+		}
+		var err error
+		store, err = OpenSecretStore(config, false)
+		return store, err
+	}
+
+	values := map[string]string{}
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		for secretIndex, _ := range cont.Secrets {
+			contSecret := &cont.Secrets[secretIndex]
+			secret, ok := secrets[contSecret.Name]
+			if !ok {
+				return fmt.Errorf("Container %v uses secret %v that the profile doesn't declare", cont.Name, contSecret.Name)
+			}
+			value, ok := values[secret.Name]
+			if !ok {
+				var err error
+				if value, err = secret.Value(configDir, openStore); err != nil {
+					return err
+				}
+				values[secret.Name] = value
+				RegisterSecretValue(value)
+			}
+			contSecret.value = value
+
+			if contSecret.Env == "" && contSecret.File == "" {
+				contSecret.File = path.Join(SecretMountDir, secret.Name)
+			}
+			if contSecret.Env != "" {
+				cont.SetEnv(contSecret.Env, strings.TrimRight(value, "\r\n"))
+			}
+		}
+	}
+	return nil
+}
+
+// Write the file secrets of a container to the secret directory of the
+// launch and mount them read-only
+func (containerArray *Containers) MountSecretFiles(cont *Container) error {
+	for _, contSecret := range cont.Secrets {
+		if contSecret.File == "" {
+			continue
+		}
+		if containerArray.SecretDir == "" {
+			root := SecretFileRoot
+			if _, err := os.Stat(root); err != nil {
+				root = os.TempDir()
+			}
+			secretDir, err := os.MkdirTemp(root, "profile-launcher-secrets-")
+			if err != nil {
+				return fmt.Errorf("Failed to create secret directory %v", err)
+			}
+			containerArray.SecretDir = secretDir
+		}
+		contDir := filepath.Join(containerArray.SecretDir, cont.Name)
+		if err := os.MkdirAll(contDir, 0700); err != nil {
+			return fmt.Errorf("Failed to create secret directory %v", err)
+		}
+		// The directory keeps other host users out, the container user may
+		// differ from the launcher user so the file itself is readable
+		secretPath := filepath.Join(contDir, contSecret.Name)
+		os.Remove(secretPath)
+		if err := os.WriteFile(secretPath, []byte(contSecret.value), 0444); err != nil {
+			return fmt.Errorf("Failed to write secret %v %v", contSecret.Name, err)
+		}
+		// A container started again already has the mount
+		if slices.ContainsFunc(cont.HostConfig.Mounts, func(mnt mount.Mount) bool { return mnt.Source == secretPath }) {
+			continue
+		}
+		spec := BindVolume(secretPath, contSecret.File)
+		spec.ReadOnly = true
+		if err := spec.AddTo(&cont.HostConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove the secret files written for the launch
+func (containerArray *Containers) RemoveSecretFiles() error {
+	if containerArray.SecretDir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(containerArray.SecretDir); err != nil {
+		return fmt.Errorf("Failed to remove secret files %v", err)
+	}
+	containerArray.SecretDir = ""
+	return nil
+}
@@ -0,0 +1,185 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Time Docker gives a container to stop when it sets no StopTimeout
+const DefaultStopTimeout = 10 * time.Second
+
+// Stops the started containers, Docker or a fake in tests
+type ShutdownRuntime interface {
+	State(ctx context.Context, cont *Container) (ContainerState, error)
+	Logs(ctx context.Context, cont *Container) (string, error)
+	// Run a command in a running container and return its exit code and output
+	Exec(ctx context.Context, cont *Container, command []string) (int, string, error)
+	// Send the stop signal and kill the container once its stop timeout is over
+	Stop(ctx context.Context, cont *Container) error
+}
+
+// Time the container is given to run its pre-stop command and to stop
+func (cont *Container) stopTimeout() time.Duration {
+	if cont.StopTimeout == nil || *cont.StopTimeout < 0 {
+		return DefaultStopTimeout
+	}
+	return time.Duration(*cont.StopTimeout) * time.Second
+}
+
+// Stop the started containers in reverse dependency order so a container
+// stops before the ones it depends on. A running container first runs its
+// PreStop command, then gets its stop signal. The final logs of each
+// container are written to logDir/<Name>.log when logDir is set. Containers
+// that fail to stop don't keep the others running.
+func (containerArray *Containers) Shutdown(ctx context.Context, runtime ShutdownRuntime, logDir string) error {
+	var errs []error
+	for contIndex := len(containerArray.Containers) - 1; contIndex >= 0; contIndex-- {
+		cont := &containerArray.Containers[contIndex]
+		if cont.ContainerID == "" {
+			continue
+		}
+		if err := cont.shutdown(ctx, runtime, logDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cont *Container) shutdown(ctx context.Context, runtime ShutdownRuntime, logDir string) error {
+	state, err := runtime.State(ctx, cont)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if state.Running {
+		if len(cont.PreStop) > 0 {
+			fmt.Printf("Running pre-stop command of container %v\n", cont.Name)
+			if err := cont.runPreStop(ctx, runtime, logDir); err != nil {
+				// The container is stopped anyway
+				errs = append(errs, err)
+			}
+		}
+		fmt.Printf("Stopping container %v\n", cont.Name)
+		if err := runtime.Stop(ctx, cont); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if state, err = runtime.State(ctx, cont); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	fmt.Printf("Container %v stopped with exit code %d\n", cont.Name, state.ExitCode)
+
+	if logDir != "" {
+		logs, err := runtime.Logs(ctx, cont)
+		if err == nil {
+			err = writeShutdownLog(logDir, cont.Name+".log", logs)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run the PreStop command of a running container within its stop timeout,
+// keeping the output in logDir/<Name>.prestop.log when logDir is set
+func (cont *Container) runPreStop(ctx context.Context, runtime ShutdownRuntime, logDir string) error {
+	timeout := cont.stopTimeout()
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	exitCode, output, err := runtime.Exec(execCtx, cont, cont.PreStop)
+	if logDir != "" && output != "" {
+		if logErr := writeShutdownLog(logDir, cont.Name+".prestop.log", output); logErr != nil && err == nil {
+			err = logErr
+		}
+	}
+	if err != nil && execCtx.Err() != nil {
+		return fmt.Errorf("Container %v: pre-stop command still running after %v", cont.Name, timeout)
+	} else if err != nil {
+		return fmt.Errorf("Container %v: pre-stop command failed %v", cont.Name, err)
+	} else if exitCode != 0 {
+		return fmt.Errorf("Container %v: pre-stop command exited with code %d", cont.Name, exitCode)
+	}
+	return nil
+}
+
+func writeShutdownLog(logDir string, fileName string, contents string) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("Failed to create log directory %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, fileName), []byte(contents), 0644); err != nil {
+		return fmt.Errorf("Failed to write log file %v", err)
+	}
+	return nil
+}
+
+func (dockerRuntime DockerRuntime) Exec(ctx context.Context, cont *Container, command []string) (int, string, error) {
+	exec, err := dockerRuntime.Client.ContainerExecCreate(ctx, cont.ContainerID, types.ExecConfig{
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	attach, err := dockerRuntime.Client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer attach.Close()
+
+	// Closing the connection ends the copy once the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+	var output strings.Builder
+	if _, err := stdcopy.StdCopy(&output, &output, attach.Reader); err != nil && ctx.Err() != nil {
+		return 0, output.String(), ctx.Err()
+	}
+	inspect, err := dockerRuntime.Client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, output.String(), err
+	}
+	return inspect.ExitCode, output.String(), nil
+}
+
+func (dockerRuntime DockerRuntime) Stop(ctx context.Context, cont *Container) error {
+	options := container.StopOptions{Signal: cont.StopSignal, Timeout: cont.StopTimeout}
+	if err := dockerRuntime.Client.ContainerStop(ctx, cont.ContainerID, options); err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("Failed to stop container %v: %v", cont.Name, err)
+	}
+	return nil
+}
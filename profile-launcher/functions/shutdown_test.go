@@ -0,0 +1,119 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Runtime recording the shutdown steps of the test containers
+type fakeShutdownRuntime struct {
+	running    map[string]bool
+	preStop    map[string]int
+	preStopErr error
+	steps      []string
+}
+
+func (fake *fakeShutdownRuntime) State(ctx context.Context, cont *Container) (ContainerState, error) {
+	if fake.running[cont.Name] {
+		return ContainerState{Running: true}, nil
+	}
+	return ContainerState{ExitCode: 143}, nil
+}
+
+func (fake *fakeShutdownRuntime) Logs(ctx context.Context, cont *Container) (string, error) {
+	return cont.Name + " logs\n", nil
+}
+
+func (fake *fakeShutdownRuntime) Exec(ctx context.Context, cont *Container, command []string) (int, string, error) {
+	fake.steps = append(fake.steps, fmt.Sprintf("exec %v %v", cont.Name, command))
+	if fake.preStopErr != nil {
+		<-ctx.Done()
+		return 0, "", fake.preStopErr
+	}
+	return fake.preStop[cont.Name], "flushed\n", nil
+}
+
+func (fake *fakeShutdownRuntime) Stop(ctx context.Context, cont *Container) error {
+	fake.steps = append(fake.steps, "stop "+cont.Name)
+	fake.running[cont.Name] = false
+	return nil
+}
+
+// Containers depending on each other, all running
+func createShutdownContainers() (Containers, *fakeShutdownRuntime) {
+	stopTimeout := 0
+	containerArray := Containers{Containers: []Container{
+		{Name: "Broker", ContainerID: "id-Broker"},
+		{Name: "Camera", ContainerID: "id-Camera", DependsOn: []string{"Broker"}, PreStop: CommandArgs{"kill", "-USR1", "1"}, StopTimeout: &stopTimeout},
+		{Name: "Setup"},
+		{Name: "Client", ContainerID: "id-Client", DependsOn: []string{"Camera"}},
+	}}
+	fake := &fakeShutdownRuntime{
+		running: map[string]bool{"Broker": true, "Camera": true, "Client": true},
+		preStop: map[string]int{},
+	}
+	return containerArray, fake
+}
+
+// TestShutdown: test stopping the containers in reverse order with their logs
+func TestShutdown(t *testing.T) {
+	containerArray, fake := createShutdownContainers()
+	fake.running["Client"] = false
+	logDir := filepath.Join(t.TempDir(), "run")
+	require.NoError(t, containerArray.Shutdown(context.Background(), fake, logDir))
+	// Client already exited, Setup was never started
+	require.Equal(t, []string{"exec Camera [kill -USR1 1]", "stop Camera", "stop Broker"}, fake.steps)
+
+	for _, name := range []string{"Broker", "Camera", "Client"} {
+		logs, err := os.ReadFile(filepath.Join(logDir, name+".log"))
+		require.NoError(t, err)
+		require.Equal(t, name+" logs\n", string(logs))
+	}
+	preStopLogs, err := os.ReadFile(filepath.Join(logDir, "Camera.prestop.log"))
+	require.NoError(t, err)
+	require.Equal(t, "flushed\n", string(preStopLogs))
+	require.NoFileExists(t, filepath.Join(logDir, "Setup.log"))
+
+	// Without a log directory nothing is written
+	containerArray, fake = createShutdownContainers()
+	require.NoError(t, containerArray.Shutdown(context.Background(), fake, ""))
+	require.Equal(t, []string{"stop Client", "exec Camera [kill -USR1 1]", "stop Camera", "stop Broker"}, fake.steps)
+}
+
+// TestShutdownPreStopErrors: test failing pre-stop commands still stopping the containers
+func TestShutdownPreStopErrors(t *testing.T) {
+	containerArray, fake := createShutdownContainers()
+	fake.preStop["Camera"] = 1
+	err := containerArray.Shutdown(context.Background(), fake, "")
+	require.EqualError(t, err, "Container Camera: pre-stop command exited with code 1")
+	require.Equal(t, []string{"stop Client", "exec Camera [kill -USR1 1]", "stop Camera", "stop Broker"}, fake.steps)
+
+	// A StopTimeout of 0 gives the pre-stop command no time
+	containerArray, fake = createShutdownContainers()
+	fake.preStopErr = context.DeadlineExceeded
+	err = containerArray.Shutdown(context.Background(), fake, "")
+	require.EqualError(t, err, "Container Camera: pre-stop command still running after 0s")
+	require.Equal(t, []string{"stop Client", "exec Camera [kill -USR1 1]", "stop Camera", "stop Broker"}, fake.steps)
+}
@@ -20,9 +20,20 @@ package functions
 import "github.com/docker/docker/api/types/container"
 
 type Containers struct {
-	Containers   []Container `yaml:"Containers"`
-	InputSrc     string      `yaml:"InputSrc"`
-	TargetDevice string      `yaml:"TargetDevice"`
+	Containers   []Container        `yaml:"Containers"`
+	InputSrc     string             `yaml:"InputSrc"`
+	TargetDevice string             `yaml:"TargetDevice"`
+	Privileged   bool               `yaml:"Privileged"`
+	Secrets      []Secret           `yaml:"Secrets"`
+	SecretStore  *SecretStoreConfig `yaml:"SecretStore"`
+	Network      *NetworkConfig     `yaml:"Network"`
+	Hooks        *Hooks             `yaml:"Hooks"`
+	// Directory the secret files of the launch are written to
+	SecretDir string `yaml:"-"`
+	// Where the hooks of the launch run
+	HookContext HookContext `yaml:"-"`
+	// Resolved to be exported for another host, its GPUs are not looked up
+	Export bool `yaml:"-"`
 }
 
 type Container struct {
@@ -30,7 +41,43 @@ type Container struct {
 	DockerImage              string               `yaml:"DockerImage"`
 	EnvironmentVariableFiles string               `yaml:"EnvironmentVariableFiles"`
 	Envs                     []string             `yaml:"Envs"`
-	Volumes                  []string             `yaml:"Volumes"`
-	Entrypoint               string               `yaml:"Entrypoint"`
+	Volumes                  []VolumeSpec         `yaml:"Volumes"`
+	Entrypoint               CommandArgs          `yaml:"Entrypoint"`
+	Command                  CommandArgs          `yaml:"Command"`
+	WorkingDir               string               `yaml:"WorkingDir"`
+	User                     string               `yaml:"User"`
+	Hostname                 string               `yaml:"Hostname"`
+	Labels                   map[string]string    `yaml:"Labels"`
+	StopSignal               string               `yaml:"StopSignal"`
+	StopTimeout              *int                 `yaml:"StopTimeout"`
+	PreStop                  CommandArgs          `yaml:"PreStop"`
+	Tty                      bool                 `yaml:"Tty"`
+	DependsOn                []string             `yaml:"DependsOn"`
+	TargetDevice             string               `yaml:"TargetDevice"`
+	InputSrc                 *ContainerInput      `yaml:"InputSrc"`
+	Secrets                  []ContainerSecret    `yaml:"Secrets"`
+	Resources                *Resources           `yaml:"Resources"`
+	Replicas                 int                  `yaml:"Replicas"`
+	Ports                    []string             `yaml:"Ports"`
+	Aliases                  []string             `yaml:"Aliases"`
+	RestartPolicy            string               `yaml:"RestartPolicy"`
+	Healthcheck              *Healthcheck         `yaml:"Healthcheck"`
 	HostConfig               container.HostConfig `yaml:"HostConfig"`
+	// Replica number from 1 once Replicas is expanded, 0 without Replicas
+	Replica int `yaml:"-"`
+	// Restarts done by the supervisor
+	Restarts int `yaml:"-"`
+	// Docker ID once the container is created
+	ContainerID string `yaml:"-"`
 }
+
+// Input source of a single container, "InputSrc: none" for containers without input
+type ContainerInput struct {
+	Required bool   `yaml:"Required"`
+	EnvName  string `yaml:"EnvName"`
+	Source   string `yaml:"Source"`
+	Disabled bool   `yaml:"-"`
+}
+
+// Command arguments given as a YAML list or a string split into shell words
+type CommandArgs []string
@@ -17,6 +17,15 @@
 
 package functions
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
 var testConfigDir = "../test-profile/valid-profile"
 var testInvalidConfigDir = "../test-profile/invalid-test-profile"
 var testInvalidFormatDir = "../test-profile/invalid-format-profile"
@@ -28,16 +37,16 @@ func CreateTestContainers(inputSrc string, targetDevice string) Containers {
 		Containers: []Container{{
 			Name:                     "Client",
 			DockerImage:              "test:dev",
-			Entrypoint:               "/script/entrypoint.sh",
+			Entrypoint:               CommandArgs{"/script/entrypoint.sh"},
 			EnvironmentVariableFiles: "profile.env",
-			Volumes:                  []string{"./test-profile:/test-profile"},
+			Volumes:                  []VolumeSpec{BindVolume("./test-profile", "/test-profile")},
 		},
 			{
 				Name:                     "Server",
 				DockerImage:              "test:dev",
-				Entrypoint:               "/script/entrypoint2.sh",
+				Entrypoint:               CommandArgs{"/script/entrypoint2.sh"},
 				EnvironmentVariableFiles: "profile2.env",
-				Volumes:                  []string{"./test-profile:/test-profile"},
+				Volumes:                  []VolumeSpec{BindVolume("./test-profile", "/test-profile")},
 			}},
 	}
 }
@@ -49,9 +58,9 @@ func CreateTestContainersInvalidImage(inputSrc string, targetDevice string) Cont
 		Containers: []Container{{
 			Name:                     "Client",
 			DockerImage:              "",
-			Entrypoint:               "/script/entrypoint.sh",
+			Entrypoint:               CommandArgs{"/script/entrypoint.sh"},
 			EnvironmentVariableFiles: "profile.env",
-			Volumes:                  []string{"./test-profile:/test-profile"},
+			Volumes:                  []VolumeSpec{BindVolume("./test-profile", "/test-profile")},
 		}},
 	}
 }
@@ -63,16 +72,92 @@ func CreateTestContainersDuplicates(inputSrc string, targetDevice string) Contai
 		Containers: []Container{{
 			Name:                     "Client",
 			DockerImage:              "test:dev",
-			Entrypoint:               "/script/entrypoint.sh",
+			Entrypoint:               CommandArgs{"/script/entrypoint.sh"},
 			EnvironmentVariableFiles: "profile.env",
-			Volumes:                  []string{"./test-profile:/test-profile"},
+			Volumes:                  []VolumeSpec{BindVolume("./test-profile", "/test-profile")},
 		},
 			{
 				Name:                     "Client",
 				DockerImage:              "test:dev",
-				Entrypoint:               "/script/entrypoint.sh",
+				Entrypoint:               CommandArgs{"/script/entrypoint.sh"},
 				EnvironmentVariableFiles: "profile.env",
-				Volumes:                  []string{"./test-profile:/test-profile"},
+				Volumes:                  []VolumeSpec{BindVolume("./test-profile", "/test-profile")},
 			}},
 	}
 }
+
+// Point the device discovery at a fake /dev tree holding the given nodes
+func CreateFakeDevTree(t *testing.T, nodes ...string) string {
+	fakeDevRoot := t.TempDir()
+	for _, node := range nodes {
+		nodePath := filepath.Join(fakeDevRoot, node)
+		require.NoError(t, os.MkdirAll(filepath.Dir(nodePath), 0755))
+		require.NoError(t, os.WriteFile(nodePath, nil, 0644))
+	}
+
+	oldDevRoot := devRoot
+	devRoot = fakeDevRoot
+	t.Cleanup(func() { devRoot = oldDevRoot })
+	return fakeDevRoot
+}
+
+// Point the sysfs discovery at an empty fake /sys tree
+func CreateFakeSysfs(t *testing.T) string {
+	fakeSysRoot := t.TempDir()
+	oldSysRoot := sysRoot
+	sysRoot = fakeSysRoot
+	t.Cleanup(func() { sysRoot = oldSysRoot })
+	return fakeSysRoot
+}
+
+// Write files of a fake sysfs tree, keys are paths relative to the root
+func WriteFakeSysfsFiles(t *testing.T, fakeSysRoot string, files map[string]string) {
+	for path, contents := range files {
+		filePath := filepath.Join(fakeSysRoot, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		require.NoError(t, os.WriteFile(filePath, []byte(contents), 0644))
+	}
+}
+
+// Add a CPU to a fake /sys/devices/system tree, nodes list their CPUs separately
+func AddFakeCPU(t *testing.T, fakeSysRoot string, cpu int, pkg int, core int) {
+	topologyDir := filepath.Join("devices", "system", "cpu", fmt.Sprintf("cpu%d", cpu), "topology")
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{
+		filepath.Join(topologyDir, "physical_package_id"): fmt.Sprintf("%d\n", pkg),
+		filepath.Join(topologyDir, "core_id"):             fmt.Sprintf("%d\n", core),
+	})
+}
+
+// Add a GPU to a fake /sys/class/drm tree
+func AddFakeGpu(t *testing.T, fakeSysRoot string, card string, renderNode string, pciAddress string, vendorID string, driver string) {
+	deviceDir := filepath.Join("class", "drm", card, "device")
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{
+		filepath.Join(deviceDir, "vendor"):                     vendorID + "\n",
+		filepath.Join(deviceDir, "device"):                     "0x46a6\n",
+		filepath.Join(deviceDir, "uevent"):                     "DRIVER=" + driver + "\nPCI_SLOT_NAME=" + pciAddress + "\n",
+		filepath.Join(deviceDir, "drm", renderNode, "dev"):     "226:128\n",
+		filepath.Join("class", "drm", card+"-HDMI-A-1", "dev"): "",
+	})
+}
+
+// Add an NPU to a fake /sys/class/accel tree
+func AddFakeNpu(t *testing.T, fakeSysRoot string, node string, pciAddress string) {
+	deviceDir := filepath.Join("class", "accel", node, "device")
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{
+		filepath.Join("class", "accel", node, "dev"): "261:0\n",
+		filepath.Join(deviceDir, "vendor"):           intelVendorID + "\n",
+		filepath.Join(deviceDir, "device"):           "0x7d1d\n",
+		filepath.Join(deviceDir, "uevent"):           "DRIVER=intel_vpu\nPCI_SLOT_NAME=" + pciAddress + "\n",
+	})
+}
+
+// Add a V4L2 node to a fake /sys/class/video4linux tree, linked to its USB interface
+func AddFakeCamera(t *testing.T, fakeSysRoot string, node string, index string, name string, usbInterface string) {
+	interfaceDir := filepath.Join(fakeSysRoot, "devices", "usb1", "1-1", usbInterface)
+	require.NoError(t, os.MkdirAll(interfaceDir, 0755))
+	WriteFakeSysfsFiles(t, fakeSysRoot, map[string]string{
+		filepath.Join("class", "video4linux", node, "name"):  name + "\n",
+		filepath.Join("class", "video4linux", node, "index"): index + "\n",
+	})
+	require.NoError(t, os.Symlink(interfaceDir, filepath.Join(fakeSysRoot, "class", "video4linux", node, "device")))
+}
@@ -0,0 +1,418 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// Restart policies of a container, the names Docker uses
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// Events of the supervisor log
+const (
+	EventExited     = "exited"
+	EventRestarting = "restarting"
+	EventRestarted  = "restarted"
+	EventGaveUp     = "gave-up"
+	EventCrashLoop  = "crash-loop"
+)
+
+// Parse a restart policy: no, always, unless-stopped, on-failure or
+// on-failure:N to give up after N restarts
+func ParseRestartPolicy(policy string) (container.RestartPolicy, error) {
+	name, count, hasCount := strings.Cut(policy, ":")
+	restartPolicy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	switch name {
+	case "":
+		if hasCount {
+			return container.RestartPolicy{}, fmt.Errorf("restart policy %q is not valid", policy)
+		}
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if hasCount {
+			return container.RestartPolicy{}, fmt.Errorf("restart policy %v takes no retry count", name)
+		}
+	case RestartOnFailure:
+		if hasCount {
+			retries, err := strconv.Atoi(count)
+			if err != nil || retries < 0 {
+				return container.RestartPolicy{}, fmt.Errorf("restart policy %q retry count must be a positive number", policy)
+			}
+			restartPolicy.MaximumRetryCount = retries
+		}
+	default:
+		return container.RestartPolicy{}, fmt.Errorf("restart policy %q must be %v, %v, %v or %v[:N]", policy, RestartNo, RestartAlways, RestartUnlessStopped, RestartOnFailure)
+	}
+	return restartPolicy, nil
+}
+
+// Set the Docker restart policy of each container with a RestartPolicy
+func (containerArray *Containers) SetRestartPolicies() error {
+	for contIndex, _ := range containerArray.Containers {
+		cont := &containerArray.Containers[contIndex]
+		if cont.RestartPolicy == "" {
+			continue
+		}
+		restartPolicy, err := ParseRestartPolicy(cont.RestartPolicy)
+		if err != nil {
+			return fmt.Errorf("Container %v: %v", cont.Name, err)
+		}
+		cont.HostConfig.RestartPolicy = restartPolicy
+	}
+	return nil
+}
+
+// Leave restarts to the supervisor, Docker restarting the containers as
+// well would restart them twice
+func (containerArray *Containers) DisableDockerRestarts() {
+	for contIndex, _ := range containerArray.Containers {
+		containerArray.Containers[contIndex].HostConfig.RestartPolicy = container.RestartPolicy{}
+	}
+}
+
+// Runs the started containers, Docker or a fake in tests
+type ContainerRuntime interface {
+	// Wait for the container to stop and return its exit code
+	Wait(ctx context.Context, cont *Container) (int64, error)
+	// Restart a stopped or running container
+	Restart(ctx context.Context, cont *Container) error
+}
+
+// Containers run by the Docker daemon
+type DockerRuntime struct {
+	Client *client.Client
+}
+
+func (dockerRuntime DockerRuntime) Wait(ctx context.Context, cont *Container) (int64, error) {
+	return cont.DockerWaitContainer(ctx, dockerRuntime.Client)
+}
+
+func (dockerRuntime DockerRuntime) Restart(ctx context.Context, cont *Container) error {
+	options := container.StopOptions{Signal: cont.StopSignal, Timeout: cont.StopTimeout}
+	if err := dockerRuntime.Client.ContainerRestart(ctx, cont.ContainerID, options); err != nil {
+		return fmt.Errorf("Failed to restart container %v: %v", cont.Name, err)
+	}
+	return nil
+}
+
+func (dockerRuntime DockerRuntime) State(ctx context.Context, cont *Container) (ContainerState, error) {
+	inspect, err := dockerRuntime.Client.ContainerInspect(ctx, cont.ContainerID)
+	if err != nil {
+		return ContainerState{}, fmt.Errorf("Failed to inspect container %v: %v", cont.Name, err)
+	}
+	state := ContainerState{}
+	if inspect.State != nil {
+		state.Running = inspect.State.Running || inspect.State.Restarting
+		state.ExitCode = inspect.State.ExitCode
+		if inspect.State.Health != nil {
+			state.Health = inspect.State.Health.Status
+		}
+	}
+	return state, nil
+}
+
+func (dockerRuntime DockerRuntime) Logs(ctx context.Context, cont *Container) (string, error) {
+	var logs strings.Builder
+	if err := cont.DockerCopyLogs(ctx, dockerRuntime.Client, &logs); err != nil {
+		return "", err
+	}
+	return logs.String(), nil
+}
+
+// Entry of the supervisor event log
+type SupervisorEvent struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	Event     string    `json:"event"`
+	ExitCode  *int64    `json:"exitCode,omitempty"`
+	Restarts  int       `json:"restarts"`
+	Backoff   string    `json:"backoff,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func (event SupervisorEvent) String() string {
+	text := fmt.Sprintf("Container %v %v", event.Container, event.Event)
+	if event.ExitCode != nil {
+		text += fmt.Sprintf(" with code %d", *event.ExitCode)
+	}
+	if event.Backoff != "" {
+		text += " in " + event.Backoff
+	}
+	if event.Reason != "" {
+		text += ": " + event.Reason
+	}
+	return text
+}
+
+// Restarts the containers by their restart policy with exponential backoff.
+// A container restarting too often is a crash loop and is left stopped, and
+// the containers depending on a restarted container are restarted after it.
+type Supervisor struct {
+	Runtime ContainerRuntime
+	// Delay of the first restart, doubled for each restart up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// A container that ran this long restarts after InitialBackoff again
+	ResetBackoffAfter time.Duration
+	// A container is restarted at most CrashLoopRestarts times within
+	// CrashLoopWindow, exiting once more is a crash loop
+	CrashLoopRestarts int
+	CrashLoopWindow   time.Duration
+	// Called for each event
+	OnEvent func(SupervisorEvent)
+	now     func() time.Time
+	after   func(time.Duration) <-chan time.Time
+}
+
+// Supervisor with the default backoff and crash loop limits
+func NewSupervisor(runtime ContainerRuntime, onEvent func(SupervisorEvent)) *Supervisor {
+	return &Supervisor{
+		Runtime:           runtime,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        time.Minute,
+		ResetBackoffAfter: time.Minute,
+		CrashLoopRestarts: 5,
+		CrashLoopWindow:   5 * time.Minute,
+		OnEvent:           onEvent,
+		now:               time.Now,
+		after:             time.After,
+	}
+}
+
+// State of a supervised container
+type supervisedContainer struct {
+	// Incremented on each restart so the exit of the previous run is ignored
+	generation int
+	running    bool
+	done       bool
+	startTime  time.Time
+	backoff    time.Duration
+	failures   int
+	restarts   []time.Time
+}
+
+type supervisorExit struct {
+	index      int
+	generation int
+	exitCode   int64
+	err        error
+}
+
+// Supervise the started containers until none is left to run. Containers
+// that crash looped or couldn't be restarted are reported as an error.
+func (supervisor *Supervisor) Supervise(ctx context.Context, containerArray *Containers) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	exits := make(chan supervisorExit)
+	restartDue := make(chan int)
+
+	states := make([]supervisedContainer, len(containerArray.Containers))
+	active := 0
+	wait := func(index int) {
+		state := &states[index]
+		state.generation++
+		state.running = true
+		state.startTime = supervisor.now()
+		generation := state.generation
+		cont := &containerArray.Containers[index]
+		go func() {
+			exitCode, err := supervisor.Runtime.Wait(ctx, cont)
+			select {
+			case exits <- supervisorExit{index, generation, exitCode, err}:
+			case <-stop:
+			}
+		}()
+	}
+	for index, cont := range containerArray.Containers {
+		if cont.ContainerID == "" {
+			states[index].done = true
+			continue
+		}
+		active++
+		wait(index)
+	}
+
+	var failed []string
+	finish := func(index int) {
+		states[index].done = true
+		states[index].running = false
+		active--
+	}
+	emit := func(index int, event SupervisorEvent) {
+		cont := &containerArray.Containers[index]
+		event.Time = supervisor.now().UTC()
+		event.Container = cont.Name
+		event.Restarts = cont.Restarts
+		if supervisor.OnEvent != nil {
+			supervisor.OnEvent(event)
+		}
+	}
+	// Restarts for a dependency don't count towards a crash loop
+	restart := func(index int, dependency string) bool {
+		cont := &containerArray.Containers[index]
+		if err := supervisor.Runtime.Restart(ctx, cont); err != nil {
+			emit(index, SupervisorEvent{Event: EventGaveUp, Reason: err.Error()})
+			failed = append(failed, cont.Name)
+			finish(index)
+			return false
+		}
+		cont.Restarts++
+		reason := ""
+		if dependency == "" {
+			states[index].restarts = append(states[index].restarts, supervisor.now())
+		} else {
+			reason = fmt.Sprintf("dependency %v restarted", dependency)
+		}
+		emit(index, SupervisorEvent{Event: EventRestarted, Reason: reason})
+		wait(index)
+		return true
+	}
+
+	for active > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case exit := <-exits:
+			state := &states[exit.index]
+			if exit.generation != state.generation || state.done {
+				continue
+			}
+			state.running = false
+			cont := &containerArray.Containers[exit.index]
+			if exit.err != nil {
+				emit(exit.index, SupervisorEvent{Event: EventGaveUp, Reason: exit.err.Error()})
+				failed = append(failed, cont.Name)
+				finish(exit.index)
+				continue
+			}
+			exitCode := exit.exitCode
+			emit(exit.index, SupervisorEvent{Event: EventExited, ExitCode: &exitCode})
+
+			restartPolicy, _ := ParseRestartPolicy(cont.RestartPolicy)
+			switch string(restartPolicy.Name) {
+			case RestartAlways, RestartUnlessStopped:
+			case RestartOnFailure:
+				if exitCode == 0 {
+					finish(exit.index)
+					continue
+				}
+				state.failures++
+				if restartPolicy.MaximumRetryCount > 0 && state.failures > restartPolicy.MaximumRetryCount {
+					emit(exit.index, SupervisorEvent{Event: EventGaveUp, Reason: fmt.Sprintf("%d restarts on failure used", restartPolicy.MaximumRetryCount)})
+					failed = append(failed, cont.Name)
+					finish(exit.index)
+					continue
+				}
+			default:
+				finish(exit.index)
+				continue
+			}
+
+			// Only the restarts within the window count towards a crash loop
+			now := supervisor.now()
+			var recent []time.Time
+			for _, restartTime := range state.restarts {
+				if now.Sub(restartTime) < supervisor.CrashLoopWindow {
+					recent = append(recent, restartTime)
+				}
+			}
+			state.restarts = recent
+			if len(recent) >= supervisor.CrashLoopRestarts {
+				emit(exit.index, SupervisorEvent{Event: EventCrashLoop, Reason: fmt.Sprintf("%d restarts within %v", len(recent), supervisor.CrashLoopWindow)})
+				failed = append(failed, cont.Name)
+				finish(exit.index)
+				continue
+			}
+
+			if state.backoff == 0 || now.Sub(state.startTime) >= supervisor.ResetBackoffAfter {
+				state.backoff = supervisor.InitialBackoff
+			} else {
+				state.backoff = min(state.backoff*2, supervisor.MaxBackoff)
+			}
+			emit(exit.index, SupervisorEvent{Event: EventRestarting, Backoff: state.backoff.String()})
+			timer := supervisor.after(state.backoff)
+			go func(index int) {
+				select {
+				case <-timer:
+					select {
+					case restartDue <- index:
+					case <-stop:
+					}
+				case <-stop:
+				}
+			}(exit.index)
+		case index := <-restartDue:
+			if states[index].done {
+				continue
+			}
+			cont := &containerArray.Containers[index]
+			if !restart(index, "") {
+				continue
+			}
+			// Dependents lost their dependency, restart the running ones after it
+			for _, dependent := range containerArray.Dependents(cont.Name) {
+				dependentIndex := containerArray.index(dependent)
+				if states[dependentIndex].running {
+					restart(dependentIndex, cont.Name)
+				}
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Containers %v stopped for good", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Names of the containers that depend on a container directly or through
+// other containers, in start order
+func (containerArray *Containers) Dependents(name string) []string {
+	dependents := map[string]bool{name: true}
+	var names []string
+	// The containers are sorted by dependencies so one pass finds them all
+	for _, cont := range containerArray.Containers {
+		for _, dependency := range cont.DependsOn {
+			if dependents[dependency] && !dependents[cont.Name] {
+				dependents[cont.Name] = true
+				names = append(names, cont.Name)
+			}
+		}
+	}
+	return names
+}
+
+func (containerArray *Containers) index(name string) int {
+	for contIndex, cont := range containerArray.Containers {
+		if cont.Name == name {
+			return contIndex
+		}
+	}
+	return -1
+}
@@ -0,0 +1,242 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+)
+
+// Runtime whose containers exit when the test says so
+type fakeRuntime struct {
+	lock      sync.Mutex
+	waiters   map[string]chan int64
+	restarted []string
+}
+
+func (fake *fakeRuntime) Wait(ctx context.Context, cont *Container) (int64, error) {
+	exit := make(chan int64, 1)
+	fake.lock.Lock()
+	fake.waiters[cont.Name] = exit
+	fake.lock.Unlock()
+	select {
+	case exitCode := <-exit:
+		return exitCode, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// A running container is killed by the restart
+func (fake *fakeRuntime) Restart(ctx context.Context, cont *Container) error {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.restarted = append(fake.restarted, cont.Name)
+	if exit, ok := fake.waiters[cont.Name]; ok {
+		delete(fake.waiters, cont.Name)
+		exit <- 137
+	}
+	return nil
+}
+
+// Make a running container exit
+func (fake *fakeRuntime) exit(t *testing.T, name string, exitCode int64) {
+	var exit chan int64
+	require.Eventually(t, func() bool {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		exit = fake.waiters[name]
+		delete(fake.waiters, name)
+		return exit != nil
+	}, time.Second, time.Millisecond)
+	exit <- exitCode
+}
+
+// Supervisor of the test containers with a fixed clock and no delays
+func startTestSupervisor(t *testing.T, containerArray *Containers, crashLoopRestarts int) (*fakeRuntime, chan SupervisorEvent, chan error, *[]time.Duration) {
+	fake := &fakeRuntime{waiters: map[string]chan int64{}}
+	events := make(chan SupervisorEvent, 100)
+	supervisor := NewSupervisor(fake, func(event SupervisorEvent) { events <- event })
+	supervisor.CrashLoopRestarts = crashLoopRestarts
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	supervisor.now = func() time.Time { return now }
+	var backoffs []time.Duration
+	supervisor.after = func(backoff time.Duration) <-chan time.Time {
+		backoffs = append(backoffs, backoff)
+		due := make(chan time.Time, 1)
+		due <- now
+		return due
+	}
+	for contIndex, _ := range containerArray.Containers {
+		containerArray.Containers[contIndex].ContainerID = "id-" + containerArray.Containers[contIndex].Name
+	}
+	result := make(chan error, 1)
+	go func() { result <- supervisor.Supervise(context.Background(), containerArray) }()
+	return fake, events, result, &backoffs
+}
+
+// Next events of the supervisor as container event pairs
+func nextEvents(t *testing.T, events chan SupervisorEvent, count int) []string {
+	var names []string
+	for i := 0; i < count; i++ {
+		select {
+		case event := <-events:
+			names = append(names, event.Container+" "+event.Event)
+		case <-time.After(time.Second):
+			require.FailNow(t, "no supervisor event", "got %v", names)
+		}
+	}
+	return names
+}
+
+// TestParseRestartPolicy: test mapping restart policies to Docker's
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		expectedErr    bool
+		expectedPolicy container.RestartPolicy
+	}{
+		{"valid empty", "", false, container.RestartPolicy{}},
+		{"valid no", "no", false, container.RestartPolicy{Name: "no"}},
+		{"valid on failure", "on-failure", false, container.RestartPolicy{Name: "on-failure"}},
+		{"valid on failure count", "on-failure:3", false, container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}},
+		{"valid unless stopped", "unless-stopped", false, container.RestartPolicy{Name: "unless-stopped"}},
+		{"valid always", "always", false, container.RestartPolicy{Name: "always"}},
+		{"invalid count", "on-failure:many", true, container.RestartPolicy{}},
+		{"invalid count on always", "always:3", true, container.RestartPolicy{}},
+		{"invalid name", "sometimes", true, container.RestartPolicy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseRestartPolicy(tt.policy)
+			require.Equal(t, tt.expectedErr, err != nil)
+			require.Equal(t, tt.expectedPolicy, policy)
+		})
+	}
+
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0].RestartPolicy = "on-failure:2"
+	require.NoError(t, tmpContainers.SetRestartPolicies())
+	require.Equal(t, container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 2}, tmpContainers.Containers[0].HostConfig.RestartPolicy)
+	require.Equal(t, container.RestartPolicy{}, tmpContainers.Containers[1].HostConfig.RestartPolicy)
+	tmpContainers.DisableDockerRestarts()
+	require.Equal(t, container.RestartPolicy{}, tmpContainers.Containers[0].HostConfig.RestartPolicy)
+	tmpContainers.Containers[1].RestartPolicy = "later"
+	require.Error(t, tmpContainers.SetRestartPolicies())
+}
+
+// TestSuperviseDependents: test restarting the dependents of a restarted container
+func TestSuperviseDependents(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0], tmpContainers.Containers[1] = tmpContainers.Containers[1], tmpContainers.Containers[0]
+	tmpContainers.Containers[0].RestartPolicy = RestartOnFailure
+	tmpContainers.Containers[1].DependsOn = []string{"Server"}
+	fake, events, result, backoffs := startTestSupervisor(t, &tmpContainers, 3)
+
+	fake.exit(t, "Server", 1)
+	require.Equal(t, []string{"Server exited", "Server restarting", "Server restarted", "Client restarted"}, nextEvents(t, events, 4))
+	fake.exit(t, "Client", 0)
+	require.Equal(t, []string{"Client exited"}, nextEvents(t, events, 1))
+	fake.exit(t, "Server", 0)
+	require.Equal(t, []string{"Server exited"}, nextEvents(t, events, 1))
+
+	require.NoError(t, <-result)
+	require.Equal(t, []string{"Server", "Client"}, fake.restarted)
+	require.Equal(t, []time.Duration{time.Second}, *backoffs)
+	require.Equal(t, 1, tmpContainers.Containers[0].Restarts)
+	require.Equal(t, 1, tmpContainers.Containers[1].Restarts)
+}
+
+// TestSuperviseCrashLoop: test backoff growing until the crash loop cap
+func TestSuperviseCrashLoop(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers = tmpContainers.Containers[:1]
+	tmpContainers.Containers[0].RestartPolicy = RestartAlways
+	fake, events, result, backoffs := startTestSupervisor(t, &tmpContainers, 3)
+
+	for restart := 0; restart < 3; restart++ {
+		fake.exit(t, "Client", 0)
+		require.Equal(t, []string{"Client exited", "Client restarting", "Client restarted"}, nextEvents(t, events, 3))
+	}
+	fake.exit(t, "Client", 2)
+	require.Equal(t, []string{"Client exited", "Client crash-loop"}, nextEvents(t, events, 2))
+
+	require.Error(t, <-result)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, *backoffs)
+}
+
+// TestSuperviseCrashLoopBoundary: test a container restarted exactly CrashLoopRestarts times
+func TestSuperviseCrashLoopBoundary(t *testing.T) {
+	for _, crashLoopRestarts := range []int{1, 2, 5} {
+		t.Run(fmt.Sprintf("%d restarts", crashLoopRestarts), func(t *testing.T) {
+			tmpContainers := CreateTestContainers("", "")
+			tmpContainers.Containers = tmpContainers.Containers[:1]
+			tmpContainers.Containers[0].RestartPolicy = RestartAlways
+			fake, events, result, _ := startTestSupervisor(t, &tmpContainers, crashLoopRestarts)
+
+			for restart := 0; restart < crashLoopRestarts; restart++ {
+				fake.exit(t, "Client", 1)
+				require.Equal(t, []string{"Client exited", "Client restarting", "Client restarted"}, nextEvents(t, events, 3))
+			}
+			fake.exit(t, "Client", 1)
+			require.Equal(t, []string{"Client exited", "Client crash-loop"}, nextEvents(t, events, 2))
+
+			require.Error(t, <-result)
+			require.Len(t, fake.restarted, crashLoopRestarts)
+			require.Equal(t, crashLoopRestarts, tmpContainers.Containers[0].Restarts)
+		})
+	}
+}
+
+// TestSuperviseRetries: test giving up once the on-failure retries are used
+func TestSuperviseRetries(t *testing.T) {
+	tmpContainers := CreateTestContainers("", "")
+	tmpContainers.Containers[0].RestartPolicy = "on-failure:1"
+	fake, events, result, _ := startTestSupervisor(t, &tmpContainers, 3)
+
+	fake.exit(t, "Client", 1)
+	require.Equal(t, []string{"Client exited", "Client restarting", "Client restarted"}, nextEvents(t, events, 3))
+	fake.exit(t, "Client", 1)
+	require.Equal(t, []string{"Client exited", "Client gave-up"}, nextEvents(t, events, 2))
+	// Containers without a restart policy are left stopped
+	fake.exit(t, "Server", 1)
+	require.Equal(t, []string{"Server exited"}, nextEvents(t, events, 1))
+
+	require.Error(t, <-result)
+	require.Equal(t, []string{"Client"}, fake.restarted)
+}
+
+// TestDependents: test finding the containers that depend on a container
+func TestDependents(t *testing.T) {
+	tmpContainers := Containers{Containers: []Container{
+		{Name: "Broker"},
+		{Name: "Server", DependsOn: []string{"Broker"}},
+		{Name: "Client", DependsOn: []string{"Server"}},
+		{Name: "Other"},
+	}}
+	require.Equal(t, []string{"Server", "Client"}, tmpContainers.Dependents("Broker"))
+	require.Equal(t, []string{"Client"}, tmpContainers.Dependents("Server"))
+	require.Empty(t, tmpContainers.Dependents("Other"))
+}
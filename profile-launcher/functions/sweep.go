@@ -0,0 +1,232 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Status of a sweep combination
+const (
+	SweepPassed  = "passed"
+	SweepFailed  = "failed"
+	SweepTimeout = "timeout"
+	SweepError   = "error"
+)
+
+// Sweep matrix file listing the values to run a profile with
+type SweepMatrix struct {
+	// Profile to run, relative to the matrix file
+	ConfigDir string `yaml:"ConfigDir"`
+	// Directory receiving one directory per combination, relative to the matrix file
+	OutputDir string `yaml:"OutputDir"`
+	// Path in the containers the combination results directory is mounted on
+	ResultsDir string `yaml:"ResultsDir"`
+	// Containers whose exit ends the combination, all containers when empty
+	WaitFor []string `yaml:"WaitFor"`
+	// Longest a combination may run such as 10m, no limit when empty
+	Timeout string `yaml:"Timeout"`
+	Matrix  struct {
+		TargetDevice []string            `yaml:"TargetDevice"`
+		InputSrc     []string            `yaml:"InputSrc"`
+		Env          map[string][]string `yaml:"Env"`
+	} `yaml:"Matrix"`
+}
+
+// Value of one matrix dimension in a combination
+type SweepParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Single run of a sweep
+type SweepCombination struct {
+	// Directory name of the combination
+	Name         string           `json:"name"`
+	Parameters   []SweepParameter `json:"parameters"`
+	TargetDevice []string         `json:"-"`
+	InputSrc     []string         `json:"-"`
+	Envs         []string         `json:"-"`
+}
+
+// Result of a combination written to its result.json
+type SweepResult struct {
+	Combination SweepCombination       `json:"combination"`
+	Status      string                 `json:"status"`
+	Error       string                 `json:"error,omitempty"`
+	StartTime   time.Time              `json:"startTime"`
+	Duration    string                 `json:"duration"`
+	Containers  []SweepContainerResult `json:"containers"`
+}
+
+// Exit of a container in a combination
+type SweepContainerResult struct {
+	Name     string `json:"name"`
+	ExitCode int64  `json:"exitCode"`
+	LogFile  string `json:"logFile,omitempty"`
+}
+
+var sweepNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9._=-]+`)
+
+// Load a sweep matrix file, relative ConfigDir and OutputDir paths are taken
+// from the directory of the matrix file
+func LoadSweepMatrix(matrixPath string) (SweepMatrix, error) {
+	matrix := SweepMatrix{}
+	contents, err := os.ReadFile(matrixPath)
+	if err != nil {
+		return matrix, fmt.Errorf("Unable to read sweep matrix: %v, error: %v", matrixPath, err)
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(contents)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&matrix); err != nil {
+		return matrix, fmt.Errorf("Sweep matrix %v: %v", matrixPath, err)
+	}
+
+	if matrix.ConfigDir == "" {
+		return matrix, fmt.Errorf("Sweep matrix %v has no ConfigDir", matrixPath)
+	}
+	if matrix.OutputDir == "" {
+		matrix.OutputDir = "sweep-results"
+	}
+	matrixDir := filepath.Dir(matrixPath)
+	for _, dir := range []*string{&matrix.ConfigDir, &matrix.OutputDir} {
+		if !filepath.IsAbs(*dir) {
+			*dir = filepath.Join(matrixDir, *dir)
+		}
+	}
+	if matrix.ResultsDir != "" && !filepath.IsAbs(matrix.ResultsDir) {
+		return matrix, fmt.Errorf("Sweep matrix %v: ResultsDir %v must be an absolute path in the containers", matrixPath, matrix.ResultsDir)
+	}
+	if _, err := matrix.GetTimeout(); err != nil {
+		return matrix, fmt.Errorf("Sweep matrix %v: %v", matrixPath, err)
+	}
+	for key, values := range matrix.Matrix.Env {
+		if key == "" || strings.Contains(key, "=") {
+			return matrix, fmt.Errorf("Sweep matrix %v: %q is not a valid env name", matrixPath, key)
+		}
+		if len(values) == 0 {
+			return matrix, fmt.Errorf("Sweep matrix %v: env %v has no values", matrixPath, key)
+		}
+	}
+	return matrix, nil
+}
+
+// Timeout of a combination, 0 when there is none
+func (matrix SweepMatrix) GetTimeout() (time.Duration, error) {
+	if matrix.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(matrix.Timeout)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("Timeout %v is not a duration such as 30s or 10m", matrix.Timeout)
+	}
+	return timeout, nil
+}
+
+// Every combination of the matrix values, the target device varies slowest
+// and env names in alphabetical order fastest
+func (matrix SweepMatrix) Combinations() []SweepCombination {
+	combinations := []SweepCombination{{}}
+	addDimension := func(name string, values []string, apply func(*SweepCombination, string)) {
+		if len(values) == 0 {
+			return
+		}
+		var expanded []SweepCombination
+		for _, combination := range combinations {
+			for _, value := range values {
+				next := SweepCombination{
+					Parameters:   append(slices.Clone(combination.Parameters), SweepParameter{Name: name, Value: value}),
+					TargetDevice: slices.Clone(combination.TargetDevice),
+					InputSrc:     slices.Clone(combination.InputSrc),
+					Envs:         slices.Clone(combination.Envs),
+				}
+				apply(&next, value)
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	addDimension("target_device", matrix.Matrix.TargetDevice, func(combination *SweepCombination, value string) {
+		combination.TargetDevice = append(combination.TargetDevice, value)
+	})
+	addDimension("inputsrc", matrix.Matrix.InputSrc, func(combination *SweepCombination, value string) {
+		combination.InputSrc = append(combination.InputSrc, value)
+	})
+	var envNames []string
+	for key := range matrix.Matrix.Env {
+		envNames = append(envNames, key)
+	}
+	sort.Strings(envNames)
+	for _, key := range envNames {
+		envName := key
+		addDimension(envName, matrix.Matrix.Env[envName], func(combination *SweepCombination, value string) {
+			combination.Envs = append(combination.Envs, envName+"="+value)
+		})
+	}
+
+	for index := range combinations {
+		nameParts := []string{fmt.Sprintf("%03d", index+1)}
+		for _, parameter := range combinations[index].Parameters {
+			nameParts = append(nameParts, sweepNameInvalidChars.ReplaceAllString(parameter.Name+"="+parameter.Value, "_"))
+		}
+		combinations[index].Name = strings.Join(nameParts, "_")
+	}
+	return combinations
+}
+
+// Write a table comparing the combinations of a sweep
+func WriteSweepSummary(output io.Writer, results []SweepResult) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	header := []string{"COMBINATION"}
+	if len(results) > 0 {
+		for _, parameter := range results[0].Combination.Parameters {
+			header = append(header, strings.ToUpper(parameter.Name))
+		}
+	}
+	header = append(header, "STATUS", "DURATION", "EXIT CODES")
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+
+	for _, result := range results {
+		row := []string{result.Combination.Name}
+		for _, parameter := range result.Combination.Parameters {
+			row = append(row, parameter.Value)
+		}
+		var exitCodes []string
+		for _, cont := range result.Containers {
+			exitCodes = append(exitCodes, fmt.Sprintf("%v=%d", cont.Name, cont.ExitCode))
+		}
+		if len(exitCodes) == 0 {
+			exitCodes = append(exitCodes, "-")
+		}
+		row = append(row, result.Status, result.Duration, strings.Join(exitCodes, ","))
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
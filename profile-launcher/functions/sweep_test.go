@@ -0,0 +1,119 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadSweepMatrix: test loading and validating sweep matrix files
+func TestLoadSweepMatrix(t *testing.T) {
+	tests := []struct {
+		name              string
+		matrix            string
+		expectedErr       bool
+		expectedConfigDir string
+		expectedOutputDir string
+	}{
+		{"valid matrix", "ConfigDir: profile\nMatrix:\n  TargetDevice: [CPU, GPU.0]\n  Env:\n    BATCH_SIZE: [1, 8]\n", false, "profile", "sweep-results"},
+		{"valid absolute dirs", "ConfigDir: /profiles/demo\nOutputDir: /results\nTimeout: 10m\n", false, "/profiles/demo", "/results"},
+		{"invalid no config dir", "Matrix:\n  TargetDevice: [CPU]\n", true, "", ""},
+		{"invalid unknown key", "ConfigDir: profile\nDevices: [CPU]\n", true, "", ""},
+		{"invalid timeout", "ConfigDir: profile\nTimeout: ten minutes\n", true, "", ""},
+		{"invalid relative results dir", "ConfigDir: profile\nResultsDir: results\n", true, "", ""},
+		{"invalid env without values", "ConfigDir: profile\nMatrix:\n  Env:\n    BATCH_SIZE: []\n", true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matrixDir := t.TempDir()
+			matrixPath := filepath.Join(matrixDir, "matrix.yaml")
+			require.NoError(t, os.WriteFile(matrixPath, []byte(tt.matrix), 0644))
+
+			hasError := false
+			matrix, err := LoadSweepMatrix(matrixPath)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				if !filepath.IsAbs(tt.expectedConfigDir) {
+					tt.expectedConfigDir = filepath.Join(matrixDir, tt.expectedConfigDir)
+				}
+				if !filepath.IsAbs(tt.expectedOutputDir) {
+					tt.expectedOutputDir = filepath.Join(matrixDir, tt.expectedOutputDir)
+				}
+				require.Equal(t, tt.expectedConfigDir, matrix.ConfigDir)
+				require.Equal(t, tt.expectedOutputDir, matrix.OutputDir)
+			}
+		})
+	}
+}
+
+// TestSweepCombinations: test expanding a matrix into combinations
+func TestSweepCombinations(t *testing.T) {
+	matrix := SweepMatrix{}
+	matrix.Matrix.TargetDevice = []string{"CPU", "Server=GPU.0"}
+	matrix.Matrix.Env = map[string][]string{"MODEL": {"yolov5s"}, "BATCH_SIZE": {"1", "8"}}
+
+	combinations := matrix.Combinations()
+	var names []string
+	for _, combination := range combinations {
+		names = append(names, combination.Name)
+	}
+	require.Equal(t, []string{
+		"001_target_device=CPU_BATCH_SIZE=1_MODEL=yolov5s",
+		"002_target_device=CPU_BATCH_SIZE=8_MODEL=yolov5s",
+		"003_target_device=Server=GPU.0_BATCH_SIZE=1_MODEL=yolov5s",
+		"004_target_device=Server=GPU.0_BATCH_SIZE=8_MODEL=yolov5s",
+	}, names)
+	require.Equal(t, []string{"Server=GPU.0"}, combinations[3].TargetDevice)
+	require.Nil(t, combinations[3].InputSrc)
+	require.Equal(t, []string{"BATCH_SIZE=8", "MODEL=yolov5s"}, combinations[3].Envs)
+	require.Equal(t, []string{"BATCH_SIZE=1", "MODEL=yolov5s"}, combinations[2].Envs)
+
+	// An empty matrix runs the profile once
+	require.Equal(t, []SweepCombination{{Name: "001"}}, SweepMatrix{}.Combinations())
+}
+
+// TestWriteSweepSummary: test the table comparing combinations
+func TestWriteSweepSummary(t *testing.T) {
+	results := []SweepResult{
+		{
+			Combination: SweepCombination{Name: "001_target_device=CPU", Parameters: []SweepParameter{{Name: "target_device", Value: "CPU"}}},
+			Status:      SweepPassed,
+			Duration:    "42s",
+			Containers:  []SweepContainerResult{{Name: "Client", ExitCode: 0}, {Name: "Server", ExitCode: 137}},
+		},
+		{
+			Combination: SweepCombination{Name: "002_target_device=GPU.0", Parameters: []SweepParameter{{Name: "target_device", Value: "GPU.0"}}},
+			Status:      SweepError,
+			Duration:    "0s",
+		},
+	}
+
+	var output bytes.Buffer
+	require.NoError(t, WriteSweepSummary(&output, results))
+	require.Equal(t, "COMBINATION              TARGET_DEVICE  STATUS  DURATION  EXIT CODES\n"+
+		"001_target_device=CPU    CPU            passed  42s       Client=0,Server=137\n"+
+		"002_target_device=GPU.0  GPU.0          error   0s        -\n", output.String())
+}
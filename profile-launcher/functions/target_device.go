@@ -0,0 +1,219 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OpenVINO virtual device plugins that run on a list of devices
+const (
+	PluginAuto   = "AUTO"
+	PluginMulti  = "MULTI"
+	PluginHetero = "HETERO"
+	PluginBatch  = "BATCH"
+)
+
+// OpenVINO device string such as GPU.1, NPU or HETERO:GPU,CPU
+type TargetDevice struct {
+	// Virtual device plugin, empty for a single device
+	Plugin string
+	// Devices the plugin runs on, AUTO without a list uses all devices
+	Devices []DeviceName
+}
+
+// Single device of an OpenVINO device string
+type DeviceName struct {
+	// Device type: CPU, GPU or NPU
+	Name string
+	// Device index, -1 when not given
+	Index int
+	// Device excluded from AUTO with a leading -
+	Excluded bool
+}
+
+// Device with an optional index and an optional (N) request or batch count
+var deviceNamePattern = regexp.MustCompile(`^(-?)(CPU|GPU|NPU)(?:\.(\d+))?(?:\((\d+)\))?$`)
+
+// Parse an OpenVINO device string using the same grammar as OpenVINO:
+// DEVICE[.N] or PLUGIN[:DEVICE[.N],...] for the AUTO, MULTI, HETERO and BATCH plugins
+func ParseTargetDevice(device string) (TargetDevice, error) {
+	targetDevice := TargetDevice{}
+	deviceList := device
+	if plugin, list, found := strings.Cut(device, ":"); found || isPlugin(plugin) {
+		if !isPlugin(plugin) {
+			return targetDevice, fmt.Errorf("Target device %v not supported, %v is not an OpenVINO device plugin", device, plugin)
+		}
+		targetDevice.Plugin = plugin
+		deviceList = list
+		if deviceList == "" {
+			if plugin != PluginAuto {
+				return targetDevice, fmt.Errorf("Target device %v needs a device list such as %v:GPU,CPU", device, plugin)
+			}
+			return targetDevice, nil
+		}
+	}
+
+	for _, item := range strings.Split(deviceList, ",") {
+		match := deviceNamePattern.FindStringSubmatch(item)
+		if match == nil {
+			return targetDevice, fmt.Errorf("Target device %v not supported, %q is not a CPU, GPU or NPU device", device, item)
+		}
+		deviceName := DeviceName{Name: match[2], Index: -1, Excluded: match[1] == "-"}
+		if match[3] != "" {
+			deviceName.Index, _ = strconv.Atoi(match[3])
+		}
+		if deviceName.Excluded && targetDevice.Plugin != PluginAuto {
+			return targetDevice, fmt.Errorf("Target device %v: only AUTO can exclude devices", device)
+		}
+		if match[4] != "" && targetDevice.Plugin != PluginMulti && targetDevice.Plugin != PluginBatch {
+			return targetDevice, fmt.Errorf("Target device %v: only MULTI and BATCH take a count in parentheses", device)
+		}
+		targetDevice.Devices = append(targetDevice.Devices, deviceName)
+	}
+	if targetDevice.Plugin == "" && len(targetDevice.Devices) > 1 {
+		return targetDevice, fmt.Errorf("Target device %v lists several devices without a plugin such as HETERO:", device)
+	}
+	if targetDevice.Plugin == PluginBatch && len(targetDevice.Devices) != 1 {
+		return targetDevice, fmt.Errorf("Target device %v: BATCH runs on exactly one device", device)
+	}
+	return targetDevice, nil
+}
+
+// Whether the target device lists a GPU, AUTO without a list only uses one
+// when the host has it
+func (targetDevice TargetDevice) UsesGpu() bool {
+	for _, device := range targetDevice.Devices {
+		if device.Name == "GPU" && !device.Excluded {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlugin(name string) bool {
+	return name == PluginAuto || name == PluginMulti || name == PluginHetero || name == PluginBatch
+}
+
+// Host device nodes and device cgroup rules the target device needs, the
+// union of the nodes of every device it lists
+func (targetDevice TargetDevice) HostDevices() ([]DeviceNode, []string, error) {
+	devices := targetDevice.Devices
+	required := true
+	if targetDevice.Plugin == PluginAuto && len(devices) == 0 {
+		// AUTO picks from whatever the host has
+		devices = []DeviceName{{Name: "GPU", Index: -1}, {Name: "NPU", Index: -1}}
+		required = false
+	}
+
+	var nodes []DeviceNode
+	var cgroupRules []string
+	seen := map[string]bool{}
+	for _, device := range devices {
+		if device.Excluded {
+			continue
+		}
+		var deviceNodes []DeviceNode
+		var rule string
+		var err error
+		switch device.Name {
+		case "CPU":
+			continue
+		case "GPU":
+			deviceNodes, rule, err = gpuHostDevices(device.Index, required)
+		case "NPU":
+			deviceNodes, rule, err = npuHostDevices(device.Index, required)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, node := range deviceNodes {
+			if !seen[node.Path] {
+				seen[node.Path] = true
+				nodes = append(nodes, node)
+			}
+		}
+		if rule != "" && !seen[rule] {
+			seen[rule] = true
+			cgroupRules = append(cgroupRules, rule)
+		}
+	}
+	return nodes, cgroupRules, nil
+}
+
+// Device nodes for GPU.index, or for every GPU when no index is given
+func gpuHostDevices(index int, required bool) ([]DeviceNode, string, error) {
+	if index < 0 {
+		gpuNodes, err := ListGpuNodes()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(gpuNodes) == 0 {
+			if required {
+				return nil, "", fmt.Errorf("Target device GPU requested but no GPU found under /dev/dri")
+			}
+			return nil, "", nil
+		}
+		return gpuNodes, fmt.Sprintf("c %d:* rmw", drmMajor), nil
+	}
+
+	// Render node numbering doesn't follow the OpenVINO index, look it up in sysfs
+	gpu, err := FindGpu(index)
+	if err != nil {
+		return nil, "", err
+	}
+	gpuNode, err := statDeviceNode("/dev/dri/" + gpu.RenderNode)
+	if err != nil {
+		return nil, "", err
+	}
+	return []DeviceNode{gpuNode}, "", nil
+}
+
+// Device nodes for NPU.index, or for every NPU when no index is given
+func npuHostDevices(index int, required bool) ([]DeviceNode, string, error) {
+	npus, err := DiscoverNpus()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nodes []DeviceNode
+	rule := ""
+	for _, npu := range npus {
+		if index >= 0 && npu.Index != index {
+			continue
+		}
+		npuNode, err := statDeviceNode("/dev/accel/" + npu.Node)
+		if err != nil {
+			return nil, "", err
+		}
+		nodes = append(nodes, npuNode)
+		if index < 0 && npu.Major != "" {
+			rule = "c " + npu.Major + ":* rmw"
+		}
+	}
+	if len(nodes) == 0 && required {
+		if index >= 0 {
+			return nil, "", fmt.Errorf("NPU.%d not found, the host has %d NPU(s), run \"profile-launcher devices\" to list them", index, len(npus))
+		}
+		return nil, "", fmt.Errorf("Target device NPU requested but no NPU found under /sys/class/accel")
+	}
+	return nodes, rule, nil
+}
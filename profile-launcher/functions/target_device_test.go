@@ -0,0 +1,119 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseTargetDevice: test parsing OpenVINO device strings
+func TestParseTargetDevice(t *testing.T) {
+	tests := []struct {
+		name           string
+		device         string
+		expectedErr    bool
+		expectedDevice TargetDevice
+	}{
+		{"valid CPU", "CPU", false, TargetDevice{Devices: []DeviceName{{Name: "CPU", Index: -1}}}},
+		{"valid GPU index", "GPU.1", false, TargetDevice{Devices: []DeviceName{{Name: "GPU", Index: 1}}}},
+		{"valid NPU", "NPU", false, TargetDevice{Devices: []DeviceName{{Name: "NPU", Index: -1}}}},
+		{"valid AUTO", "AUTO", false, TargetDevice{Plugin: PluginAuto}},
+		{"valid AUTO exclusion", "AUTO:-CPU", false, TargetDevice{Plugin: PluginAuto, Devices: []DeviceName{{Name: "CPU", Index: -1, Excluded: true}}}},
+		{"valid HETERO", "HETERO:GPU,CPU", false, TargetDevice{Plugin: PluginHetero, Devices: []DeviceName{{Name: "GPU", Index: -1}, {Name: "CPU", Index: -1}}}},
+		{"valid MULTI with requests", "MULTI:GPU.0(4),CPU(2)", false, TargetDevice{Plugin: PluginMulti, Devices: []DeviceName{{Name: "GPU", Index: 0}, {Name: "CPU", Index: -1}}}},
+		{"valid BATCH", "BATCH:GPU(4)", false, TargetDevice{Plugin: PluginBatch, Devices: []DeviceName{{Name: "GPU", Index: -1}}}},
+		{"invalid device", "FPGA", true, TargetDevice{}},
+		{"invalid lowercase device", "gpu", true, TargetDevice{}},
+		{"invalid plugin", "FOO:GPU", true, TargetDevice{}},
+		{"invalid MULTI without devices", "MULTI", true, TargetDevice{}},
+		{"invalid empty device in list", "HETERO:GPU,", true, TargetDevice{}},
+		{"invalid exclusion outside AUTO", "HETERO:-CPU,GPU", true, TargetDevice{}},
+		{"invalid count outside MULTI", "HETERO:GPU(4)", true, TargetDevice{}},
+		{"invalid list without plugin", "GPU,CPU", true, TargetDevice{}},
+		{"invalid BATCH with two devices", "BATCH:GPU,CPU", true, TargetDevice{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasError := false
+			targetDevice, err := ParseTargetDevice(tt.device)
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedDevice, targetDevice)
+			}
+		})
+	}
+}
+
+// TestTargetDeviceUsesGpu: test finding target devices that use a GPU
+func TestTargetDeviceUsesGpu(t *testing.T) {
+	for device, expected := range map[string]bool{"GPU.1": true, "HETERO:GPU,CPU": true, "CPU": false, "NPU": false, "AUTO": false, "AUTO:-GPU": false} {
+		targetDevice, err := ParseTargetDevice(device)
+		require.NoError(t, err)
+		require.Equal(t, expected, targetDevice.UsesGpu(), device)
+	}
+}
+
+// TestHostDevices: test computing the device nodes of a target device
+func TestHostDevices(t *testing.T) {
+	CreateFakeDevTree(t, "dri/card0", "dri/renderD128", "dri/card1", "dri/renderD129", "accel/accel0")
+	fakeSysRoot := CreateFakeSysfs(t)
+	AddFakeGpu(t, fakeSysRoot, "card0", "renderD128", "0000:00:02.0", intelVendorID, "i915")
+	AddFakeGpu(t, fakeSysRoot, "card1", "renderD129", "0000:03:00.0", intelVendorID, "i915")
+	AddFakeNpu(t, fakeSysRoot, "accel0", "0000:00:0b.0")
+
+	tests := []struct {
+		name                string
+		device              string
+		expectedErr         bool
+		expectedPaths       []string
+		expectedCgroupRules []string
+	}{
+		{"valid CPU", "CPU", false, nil, nil},
+		{"valid GPU.1", "GPU.1", false, []string{"/dev/dri/renderD129"}, nil},
+		{"valid NPU", "NPU", false, []string{"/dev/accel/accel0"}, []string{"c 261:* rmw"}},
+		{"valid HETERO union", "HETERO:GPU.1,GPU.0,NPU,CPU", false, []string{"/dev/dri/renderD129", "/dev/dri/renderD128", "/dev/accel/accel0"}, []string{"c 261:* rmw"}},
+		{"valid MULTI deduplicated", "MULTI:GPU,GPU.0", false, []string{"/dev/dri/card0", "/dev/dri/card1", "/dev/dri/renderD128", "/dev/dri/renderD129"}, []string{"c 226:* rmw"}},
+		{"valid AUTO excluding NPU", "AUTO:GPU.0,-NPU", false, []string{"/dev/dri/renderD128"}, nil},
+		{"invalid missing GPU.2", "HETERO:GPU.2,CPU", true, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDevice, err := ParseTargetDevice(tt.device)
+			require.NoError(t, err)
+
+			hasError := false
+			nodes, cgroupRules, err := targetDevice.HostDevices()
+			if err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+
+			var paths []string
+			for _, node := range nodes {
+				paths = append(paths, node.Path)
+			}
+			require.Equal(t, tt.expectedPaths, paths)
+			require.Equal(t, tt.expectedCgroupRules, cgroupRules)
+		})
+	}
+}
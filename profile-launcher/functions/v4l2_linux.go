@@ -0,0 +1,67 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// struct v4l2_capability from linux/videodev2.h
+type v4l2Capability struct {
+	Driver       [16]byte
+	Card         [32]byte
+	BusInfo      [32]byte
+	Version      uint32
+	Capabilities uint32
+	DeviceCaps   uint32
+	Reserved     [3]uint32
+}
+
+const (
+	// _IOR('V', 0, struct v4l2_capability)
+	vidiocQueryCap = 0x80685600
+
+	v4l2CapVideoCapture       = 0x00000001
+	v4l2CapVideoCaptureMplane = 0x00001000
+	v4l2CapDeviceCaps         = 0x80000000
+)
+
+// Query the card name, bus info and capture capability of a V4L2 node
+func queryV4l2Capability(path string) (name string, busInfo string, capture bool, err error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer file.Close()
+
+	capability := v4l2Capability{}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), vidiocQueryCap, uintptr(unsafe.Pointer(&capability))); errno != 0 {
+		return "", "", false, errno
+	}
+	// Device caps describe this node, capabilities the whole physical device
+	caps := capability.Capabilities
+	if caps&v4l2CapDeviceCaps != 0 {
+		caps = capability.DeviceCaps
+	}
+	name = string(bytes.TrimRight(capability.Card[:], "\x00"))
+	busInfo = string(bytes.TrimRight(capability.BusInfo[:], "\x00"))
+	return name, busInfo, caps&(v4l2CapVideoCapture|v4l2CapVideoCaptureMplane) != 0, nil
+}
@@ -0,0 +1,27 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+//go:build !linux
+
+package functions
+
+import "errors"
+
+// V4L2 is only available on Linux
+func queryV4l2Capability(path string) (name string, busInfo string, capture bool, err error) {
+	return "", "", false, errors.New("V4L2 is not supported on this platform")
+}
@@ -0,0 +1,346 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// Volume of a container, given in the short form source:target[:options] or
+// as a mapping
+type VolumeSpec struct {
+	// bind (default), volume or tmpfs
+	Type     mount.Type          `yaml:"Type,omitempty"`
+	Source   string              `yaml:"Source,omitempty"`
+	Target   string              `yaml:"Target"`
+	ReadOnly bool                `yaml:"ReadOnly,omitempty"`
+	Bind     *BindOptions        `yaml:"Bind,omitempty"`
+	Volume   *NamedVolumeOptions `yaml:"Volume,omitempty"`
+	Tmpfs    *TmpfsOptions       `yaml:"Tmpfs,omitempty"`
+}
+
+type BindOptions struct {
+	// shared, slave, private, rshared, rslave or rprivate
+	Propagation mount.Propagation `yaml:"Propagation,omitempty"`
+	// z shares the SELinux label between containers, Z keeps it private
+	SELinux string `yaml:"SELinux,omitempty"`
+}
+
+type NamedVolumeOptions struct {
+	// Don't copy the image content at the target into a new volume
+	NoCopy bool `yaml:"NoCopy,omitempty"`
+}
+
+type TmpfsOptions struct {
+	// Size such as 64m or 1g, unlimited when empty
+	Size string `yaml:"Size,omitempty"`
+}
+
+var propagationModes = []mount.Propagation{
+	mount.PropagationShared, mount.PropagationSlave, mount.PropagationPrivate,
+	mount.PropagationRShared, mount.PropagationRSlave, mount.PropagationRPrivate,
+}
+
+// Accept the short form string or the long form mapping
+func (spec *VolumeSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		parsed, err := ParseVolume(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		*spec = parsed
+		return nil
+	}
+	// Decode through another type to not call UnmarshalYAML again
+	type longVolumeSpec VolumeSpec
+	long := longVolumeSpec{}
+	if err := node.Decode(&long); err != nil {
+		return err
+	}
+	*spec = VolumeSpec(long)
+	if err := spec.validate(); err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	return nil
+}
+
+// Write volumes in the short form when it holds all their settings
+func (spec VolumeSpec) MarshalYAML() (interface{}, error) {
+	if short, ok := spec.shortForm(); ok {
+		return short, nil
+	}
+	type longVolumeSpec VolumeSpec
+	return longVolumeSpec(spec), nil
+}
+
+// Parse a volume in the short form source:target[:options]. A source that
+// is a name rather than a path is a named Docker volume. Options are ro, rw,
+// z, Z, nocopy and the bind propagation modes, separated by commas. Paths
+// can contain colons, the target is taken from the last ":/".
+func ParseVolume(vol string) (VolumeSpec, error) {
+	spec := VolumeSpec{}
+	rest := vol
+	var options []string
+	if index := strings.LastIndex(rest, ":"); index >= 0 && isVolumeOptions(rest[index+1:]) {
+		options = strings.Split(rest[index+1:], ",")
+		rest = rest[:index]
+	}
+
+	index := strings.LastIndex(rest, ":/")
+	if index < 0 {
+		index = strings.LastIndex(rest, ":")
+	}
+	if index <= 0 || index == len(rest)-1 {
+		return spec, fmt.Errorf("Volume %v format incorrect, Ensure format is (source):(destination)[:options]", vol)
+	}
+	spec.Source = rest[:index]
+	spec.Target = rest[index+1:]
+	spec.Type = mount.TypeBind
+	if isVolumeName(spec.Source) {
+		spec.Type = mount.TypeVolume
+	}
+
+	readWrite := false
+	for _, option := range options {
+		switch option {
+		case "ro":
+			spec.ReadOnly = true
+		case "rw":
+			readWrite = true
+		case "z", "Z":
+			if spec.Bind == nil {
+				spec.Bind = &BindOptions{}
+			}
+			if spec.Bind.SELinux != "" {
+				return spec, fmt.Errorf("Volume %v has more than one SELinux option", vol)
+			}
+			spec.Bind.SELinux = option
+		case "nocopy":
+			spec.Volume = &NamedVolumeOptions{NoCopy: true}
+		default:
+			if spec.Bind == nil {
+				spec.Bind = &BindOptions{}
+			}
+			if spec.Bind.Propagation != "" {
+				return spec, fmt.Errorf("Volume %v has more than one propagation option", vol)
+			}
+			spec.Bind.Propagation = mount.Propagation(option)
+		}
+	}
+	if spec.ReadOnly && readWrite {
+		return spec, fmt.Errorf("Volume %v can't be both ro and rw", vol)
+	}
+	if err := spec.validate(); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// Options field of a short form volume, so a path isn't taken for options
+func isVolumeOptions(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, option := range strings.Split(field, ",") {
+		if !slices.Contains([]string{"ro", "rw", "z", "Z", "nocopy"}, option) && !slices.Contains(propagationModes, mount.Propagation(option)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Names of Docker volumes don't contain a slash and don't start with a dot
+// or tilde like relative paths do
+func isVolumeName(source string) bool {
+	return !strings.Contains(source, "/") && !strings.HasPrefix(source, ".") && !strings.HasPrefix(source, "~")
+}
+
+// Plain names were relative binds before named volumes were supported, warn
+// when a named volume has the name of a local path
+func (spec VolumeSpec) namedVolumeWarning() string {
+	if spec.Type != mount.TypeVolume || spec.Source == "" {
+		return ""
+	}
+	if _, err := os.Stat(spec.Source); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("Volume %v mounts the named Docker volume %v, not the local path, use ./%v to bind mount it", spec.Target, spec.Source, spec.Source)
+}
+
+// Path with a leading ~ replaced by the home directory of the user
+func expandHome(hostPath string) string {
+	if hostPath != "~" && !strings.HasPrefix(hostPath, "~/") {
+		return hostPath
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return hostPath
+	}
+	return filepath.Join(home, strings.TrimPrefix(hostPath, "~"))
+}
+
+// Check the options apply to the volume type
+func (spec *VolumeSpec) validate() error {
+	if spec.Type == "" {
+		spec.Type = mount.TypeBind
+	}
+	if spec.Target == "" {
+		return fmt.Errorf("Volume %v has no Target", spec.Source)
+	}
+	switch spec.Type {
+	case mount.TypeBind:
+		if spec.Source == "" {
+			return fmt.Errorf("Bind volume %v has no Source", spec.Target)
+		}
+	case mount.TypeVolume:
+		if spec.Source != "" && !isVolumeName(spec.Source) {
+			return fmt.Errorf("Volume %v: %v is not a valid volume name", spec.Target, spec.Source)
+		}
+	case mount.TypeTmpfs:
+		if spec.Source != "" {
+			return fmt.Errorf("Tmpfs volume %v can't have a Source", spec.Target)
+		}
+		if spec.Tmpfs != nil && spec.Tmpfs.Size != "" {
+			if _, err := units.RAMInBytes(spec.Tmpfs.Size); err != nil {
+				return fmt.Errorf("Tmpfs volume %v: invalid Size %v", spec.Target, spec.Tmpfs.Size)
+			}
+		}
+	default:
+		return fmt.Errorf("Volume %v has type %v, use %v, %v or %v", spec.Target, spec.Type, mount.TypeBind, mount.TypeVolume, mount.TypeTmpfs)
+	}
+
+	if spec.Bind != nil && spec.Type != mount.TypeBind {
+		return fmt.Errorf("Volume %v: bind options only apply to bind volumes", spec.Target)
+	}
+	if spec.Volume != nil && spec.Type != mount.TypeVolume {
+		return fmt.Errorf("Volume %v: nocopy only applies to named volumes", spec.Target)
+	}
+	if spec.Tmpfs != nil && spec.Type != mount.TypeTmpfs {
+		return fmt.Errorf("Volume %v: tmpfs options only apply to tmpfs volumes", spec.Target)
+	}
+	if spec.Bind != nil {
+		if spec.Bind.Propagation != "" && !slices.Contains(propagationModes, spec.Bind.Propagation) {
+			return fmt.Errorf("Volume %v has unknown propagation %v", spec.Target, spec.Bind.Propagation)
+		}
+		if spec.Bind.SELinux != "" && spec.Bind.SELinux != "z" && spec.Bind.SELinux != "Z" {
+			return fmt.Errorf("Volume %v has SELinux option %v, use z or Z", spec.Target, spec.Bind.SELinux)
+		}
+	}
+	return nil
+}
+
+// Short form of the volume, false when it needs the long form
+func (spec VolumeSpec) shortForm() (string, bool) {
+	if spec.Type == mount.TypeTmpfs || spec.Source == "" {
+		return "", false
+	}
+	if spec.Type == mount.TypeVolume && !isVolumeName(spec.Source) || spec.Type != mount.TypeVolume && isVolumeName(spec.Source) {
+		return "", false
+	}
+	var options []string
+	if spec.ReadOnly {
+		options = append(options, "ro")
+	}
+	if spec.Bind != nil {
+		if spec.Bind.SELinux != "" {
+			options = append(options, spec.Bind.SELinux)
+		}
+		if spec.Bind.Propagation != "" {
+			options = append(options, string(spec.Bind.Propagation))
+		}
+	}
+	if spec.Volume != nil && spec.Volume.NoCopy {
+		options = append(options, "nocopy")
+	}
+	short := spec.Source + ":" + spec.Target
+	if len(options) > 0 {
+		short += ":" + strings.Join(options, ",")
+	}
+	return short, true
+}
+
+// Docker mount of the volume, bind sources are made absolute with a leading
+// ~ taken as the home directory
+func (spec VolumeSpec) Mount() (mount.Mount, error) {
+	if err := spec.validate(); err != nil {
+		return mount.Mount{}, err
+	}
+	volMount := mount.Mount{
+		Type:     spec.Type,
+		Source:   spec.Source,
+		Target:   spec.Target,
+		ReadOnly: spec.ReadOnly,
+	}
+	switch spec.Type {
+	case mount.TypeBind:
+		sourcePath, err := filepath.Abs(expandHome(spec.Source))
+		if err != nil {
+			return mount.Mount{}, fmt.Errorf("Failed to get volume path %v", err)
+		}
+		volMount.Source = sourcePath
+		if spec.Bind != nil && spec.Bind.Propagation != "" {
+			volMount.BindOptions = &mount.BindOptions{Propagation: spec.Bind.Propagation}
+		}
+	case mount.TypeVolume:
+		if spec.Volume != nil && spec.Volume.NoCopy {
+			volMount.VolumeOptions = &mount.VolumeOptions{NoCopy: true}
+		}
+	case mount.TypeTmpfs:
+		if spec.Tmpfs != nil && spec.Tmpfs.Size != "" {
+			size, _ := units.RAMInBytes(spec.Tmpfs.Size)
+			volMount.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: size}
+		}
+	}
+	return volMount, nil
+}
+
+// Add the volume to the host config. SELinux relabeling is only supported
+// for binds in the legacy source:target:options form, other volumes are mounts.
+func (spec VolumeSpec) AddTo(hostConfig *container.HostConfig) error {
+	volMount, err := spec.Mount()
+	if err != nil {
+		return err
+	}
+	if spec.Bind == nil || spec.Bind.SELinux == "" {
+		hostConfig.Mounts = append(hostConfig.Mounts, volMount)
+		return nil
+	}
+	options := []string{"rw", spec.Bind.SELinux}
+	if spec.ReadOnly {
+		options[0] = "ro"
+	}
+	if spec.Bind.Propagation != "" {
+		options = append(options, string(spec.Bind.Propagation))
+	}
+	hostConfig.Binds = append(hostConfig.Binds, volMount.Source+":"+volMount.Target+":"+strings.Join(options, ","))
+	return nil
+}
+
+// Bind mount of a path from the host
+func BindVolume(source string, target string) VolumeSpec {
+	return VolumeSpec{Type: mount.TypeBind, Source: source, Target: target}
+}
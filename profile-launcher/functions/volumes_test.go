@@ -0,0 +1,151 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package functions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestParseVolume: test parsing short form volumes
+func TestParseVolume(t *testing.T) {
+	tests := []struct {
+		name         string
+		volume       string
+		expectedErr  bool
+		expectedSpec VolumeSpec
+	}{
+		{"valid bind", "./models:/models", false, VolumeSpec{Type: mount.TypeBind, Source: "./models", Target: "/models"}},
+		{"valid read only bind", "/opt/models:/models:ro", false, VolumeSpec{Type: mount.TypeBind, Source: "/opt/models", Target: "/models", ReadOnly: true}},
+		{"valid read write bind", "/opt/models:/models:rw", false, VolumeSpec{Type: mount.TypeBind, Source: "/opt/models", Target: "/models"}},
+		{"valid bind options", "/opt/models:/models:ro,Z,rshared", false, VolumeSpec{Type: mount.TypeBind, Source: "/opt/models", Target: "/models", ReadOnly: true, Bind: &BindOptions{Propagation: mount.PropagationRShared, SELinux: "Z"}}},
+		{"valid colon in source", "/dev/dri/by-path/pci-0000:00:02.0-render:/dev/dri/renderD128", false, VolumeSpec{Type: mount.TypeBind, Source: "/dev/dri/by-path/pci-0000:00:02.0-render", Target: "/dev/dri/renderD128"}},
+		{"valid colon in source with options", "/data/a:b:/data:ro", false, VolumeSpec{Type: mount.TypeBind, Source: "/data/a:b", Target: "/data", ReadOnly: true}},
+		{"valid named volume", "cache:/cache", false, VolumeSpec{Type: mount.TypeVolume, Source: "cache", Target: "/cache"}},
+		{"valid named volume nocopy", "cache:/cache:nocopy", false, VolumeSpec{Type: mount.TypeVolume, Source: "cache", Target: "/cache", Volume: &NamedVolumeOptions{NoCopy: true}}},
+		{"invalid no target", "./models", true, VolumeSpec{}},
+		{"invalid empty target", "./models:", true, VolumeSpec{}},
+		{"invalid ro and rw", "./models:/models:ro,rw", true, VolumeSpec{}},
+		{"invalid two propagations", "./models:/models:shared,slave", true, VolumeSpec{}},
+		{"invalid relabel named volume", "cache:/cache:z", true, VolumeSpec{}},
+		{"invalid nocopy bind", "./cache:/cache:nocopy", true, VolumeSpec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseVolume(tt.volume)
+			require.Equal(t, tt.expectedErr, err != nil)
+			if !tt.expectedErr {
+				require.Equal(t, tt.expectedSpec, spec)
+			}
+		})
+	}
+}
+
+// TestVolumeSpecYaml: test the short and long forms of profile volumes
+func TestVolumeSpecYaml(t *testing.T) {
+	tests := []struct {
+		name         string
+		yaml         string
+		expectedErr  bool
+		expectedSpec VolumeSpec
+	}{
+		{"valid short form", "./models:/models:ro", false, VolumeSpec{Type: mount.TypeBind, Source: "./models", Target: "/models", ReadOnly: true}},
+		{"valid bind default type", "Source: ./models\nTarget: /models\nBind:\n  Propagation: rslave", false, VolumeSpec{Type: mount.TypeBind, Source: "./models", Target: "/models", Bind: &BindOptions{Propagation: mount.PropagationRSlave}}},
+		{"valid named volume", "Type: volume\nSource: cache\nTarget: /cache", false, VolumeSpec{Type: mount.TypeVolume, Source: "cache", Target: "/cache"}},
+		{"valid tmpfs", "Type: tmpfs\nTarget: /scratch\nTmpfs:\n  Size: 64m", false, VolumeSpec{Type: mount.TypeTmpfs, Target: "/scratch", Tmpfs: &TmpfsOptions{Size: "64m"}}},
+		{"invalid short form", "./models", true, VolumeSpec{}},
+		{"invalid type", "Type: npipe\nSource: a\nTarget: /a", true, VolumeSpec{}},
+		{"invalid bind without source", "Target: /models", true, VolumeSpec{}},
+		{"invalid tmpfs source", "Type: tmpfs\nSource: ./scratch\nTarget: /scratch", true, VolumeSpec{}},
+		{"invalid tmpfs size", "Type: tmpfs\nTarget: /scratch\nTmpfs:\n  Size: big", true, VolumeSpec{}},
+		{"invalid propagation", "Source: ./models\nTarget: /models\nBind:\n  Propagation: everywhere", true, VolumeSpec{}},
+		{"invalid tmpfs options on bind", "Source: ./models\nTarget: /models\nTmpfs:\n  Size: 64m", true, VolumeSpec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := VolumeSpec{}
+			hasError := false
+			if err := yaml.Unmarshal([]byte(tt.yaml), &spec); err != nil {
+				hasError = true
+			}
+			require.Equal(t, tt.expectedErr, hasError)
+			if tt.expectedErr {
+				return
+			}
+			require.Equal(t, tt.expectedSpec, spec)
+
+			// Volumes written back load the same
+			contents, err := yaml.Marshal(spec)
+			require.NoError(t, err)
+			reloaded := VolumeSpec{}
+			require.NoError(t, yaml.Unmarshal(contents, &reloaded))
+			require.Equal(t, spec, reloaded)
+		})
+	}
+}
+
+// TestVolumeSpecAddTo: test adding volumes to the host config
+func TestVolumeSpecAddTo(t *testing.T) {
+	modelsPath, err := filepath.Abs("./models")
+	require.NoError(t, err)
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	hostConfig := container.HostConfig{}
+	require.NoError(t, VolumeSpec{Type: mount.TypeBind, Source: "./models", Target: "/models", ReadOnly: true}.AddTo(&hostConfig))
+	require.NoError(t, VolumeSpec{Type: mount.TypeTmpfs, Target: "/scratch", Tmpfs: &TmpfsOptions{Size: "64m"}}.AddTo(&hostConfig))
+	homeSpec, err := ParseVolume("~/models:/home-models")
+	require.NoError(t, err)
+	require.NoError(t, homeSpec.AddTo(&hostConfig))
+	require.NoError(t, VolumeSpec{Type: mount.TypeBind, Source: "./models", Target: "/labeled", Bind: &BindOptions{SELinux: "z", Propagation: mount.PropagationRSlave}}.AddTo(&hostConfig))
+	require.Error(t, VolumeSpec{Type: mount.TypeBind, Target: "/models"}.AddTo(&hostConfig))
+
+	require.Equal(t, []mount.Mount{
+		{Type: mount.TypeBind, Source: modelsPath, Target: "/models", ReadOnly: true},
+		{Type: mount.TypeTmpfs, Target: "/scratch", TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 64 * 1024 * 1024}},
+		{Type: mount.TypeBind, Source: filepath.Join(home, "models"), Target: "/home-models"},
+	}, hostConfig.Mounts)
+	// SELinux relabeling is only available for binds
+	require.Equal(t, []string{modelsPath + ":/labeled:rw,z,rslave"}, hostConfig.Binds)
+}
+
+// TestNamedVolumeWarning: test warning about named volumes named like a local path
+func TestNamedVolumeWarning(t *testing.T) {
+	workingDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(workingDir) })
+	require.NoError(t, os.Mkdir("data", 0755))
+
+	spec, err := ParseVolume("data:/data")
+	require.NoError(t, err)
+	require.Equal(t, mount.TypeVolume, spec.Type)
+	require.Contains(t, spec.namedVolumeWarning(), "use ./data")
+
+	for _, vol := range []string{"cache:/cache", "./data:/data"} {
+		spec, err := ParseVolume(vol)
+		require.NoError(t, err)
+		require.Empty(t, spec.namedVolumeWarning())
+	}
+}
@@ -19,8 +19,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/intel-retail/core-services/profile-launcher/functions"
@@ -40,71 +49,290 @@ func (i *arrayFlags) Set(value string) error {
 
 type envOverrideFlags []string
 
-func main() {
-	var envOverrides arrayFlags
-	var volumes arrayFlags
-	var configDir string
-	var targetDevice string
-	var inputSrc string
-	var renderMode bool
-	if flag.Lookup("configdir") == nil {
-		flag.StringVar(&configDir, "configdir", "./test-profile/valid-profile", "Directory with the profile config")
+// Flags that resolve a profile, shared by the launch and the subcommands
+type profileFlags struct {
+	envOverrides arrayFlags
+	unsetEnvs    arrayFlags
+	volumes      arrayFlags
+	configDir    string
+	targetDevice arrayFlags
+	inputSrc     arrayFlags
+	renderMode   bool
+	privileged   bool
+	// Resolve the profile for another host, export sets it
+	export bool
+	// Spread the replicas over the host CPUs, core or numa
+	cpusetPerReplica string
+}
+
+func (flags *profileFlags) register(flagSet *flag.FlagSet) {
+	if flagSet.Lookup("configdir") == nil {
+		flagSet.StringVar(&flags.configDir, "configdir", "./test-profile/valid-profile", "Directory with the profile config")
 	}
-	if flag.Lookup("target_device") == nil {
-		flag.StringVar(&targetDevice, "target_device", "", "Device you are targeting to run on. Default is CPU.")
+	if flagSet.Lookup("target_device") == nil {
+		flagSet.Var(&flags.targetDevice, "target_device", "Device you are targeting to run on, Name=Device targets a single container. Default is CPU.")
 	}
-	if flag.Lookup("inputsrc") == nil {
-		flag.StringVar(&inputSrc, "inputsrc", "", "Input for the profile to use.")
+	if flagSet.Lookup("inputsrc") == nil {
+		flagSet.Var(&flags.inputSrc, "inputsrc", "Input for the profile to use, Name=Source targets a single container.")
 	}
-	if flag.Lookup("v") == nil {
-		flag.Var(&volumes, "v", "Volume mount for the container")
+	if flagSet.Lookup("v") == nil {
+		flagSet.Var(&flags.volumes, "v", "Volume mount for the containers, Name:source:target mounts into a single container")
 	}
-	if flag.Lookup("e") == nil {
-		flag.Var(&envOverrides, "e", "Environment overridees for the container")
+	if flagSet.Lookup("e") == nil {
+		flagSet.Var(&flags.envOverrides, "e", "Environment overridees for the containers, KEY takes the host value and Name:KEY=VALUE targets a single container")
 	}
-	if flag.Lookup("render_mode") == nil {
-		flag.BoolVar(&renderMode, "render_mode", false, "Enable render mode when set to 1.")
+	if flagSet.Lookup("unset") == nil {
+		flagSet.Var(&flags.unsetEnvs, "unset", "Environment variable removed from the containers, Name:KEY targets a single container")
+	}
+	if flagSet.Lookup("render_mode") == nil {
+		flagSet.BoolVar(&flags.renderMode, "render_mode", false, "Enable render mode when set to 1.")
+	}
+	if flagSet.Lookup("privileged") == nil {
+		flagSet.BoolVar(&flags.privileged, "privileged", false, "Run the containers in privileged mode instead of mapping only the needed devices.")
+	}
+	if flagSet.Lookup("cpuset-per-replica") == nil {
+		flagSet.StringVar(&flags.cpusetPerReplica, "cpuset-per-replica", "", "Pin the replicas to physical cores that don't overlap, core splits all cores and numa keeps each replica on one NUMA node")
+	}
+}
+
+// Flags of the launch itself, not needed to resolve the profile
+type launchFlags struct {
+	runDir     string
+	foreground bool
+	supervise  bool
+	// Longest wait for the containers to be ready, 0 to not wait
+	readyTimeout time.Duration
+}
+
+func (flags *launchFlags) register(flagSet *flag.FlagSet) {
+	if flagSet.Lookup("run_dir") == nil {
+		flagSet.StringVar(&flags.runDir, "run_dir", "runs", "Directory the run.json report of each launch is written under, empty to disable it")
+	}
+	if flagSet.Lookup("foreground") == nil {
+		flagSet.BoolVar(&flags.foreground, "foreground", false, "Wait for the containers to exit and record their exit codes in the run report")
+	}
+	if flagSet.Lookup("ready_timeout") == nil {
+		flagSet.DurationVar(&flags.readyTimeout, "ready_timeout", 2*time.Minute, "Longest wait for the containers to be running, healthy and passing their probes, 0 to not wait")
+	}
+	if flagSet.Lookup("supervise") == nil {
+		flagSet.BoolVar(&flags.supervise, "supervise", false, "Run in the foreground and restart the containers by their RestartPolicy with backoff, restarts are logged to events.jsonl in the run directory")
+	}
+}
+
+// Run the containers and keep the run report up to date while they run
+func (launch *launchFlags) run(containersArray *functions.Containers, report *functions.RunReport) error {
+	reportPath := filepath.Join(launch.runDir, report.RunID, functions.RunReportFile)
+	writeReport := func() {
+		if launch.runDir == "" {
+			return
+		}
+		if err := WriteRunReport(report, containersArray, reportPath); err != nil {
+			fmt.Printf("Failed to write run report %v\n", err)
+		} else {
+			fmt.Println("Run report written to", reportPath)
+		}
+	}
+
+	containersArray.HookContext.RunID = report.RunID
+	if launch.runDir != "" {
+		containersArray.HookContext.LogDir = filepath.Dir(reportPath)
+	}
+
+	// The first SIGINT or SIGTERM stops the containers, a second one ends
+	// the launcher right away
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	shutdown := func(reason string) error {
+		stopSignals()
+		fmt.Println(reason + ", stopping the containers")
+		shutdownErr := ShutdownContainers(containersArray, containersArray.HookContext.LogDir)
+		report.Finish()
+		writeReport()
+		hookErr := RunHooks(context.Background(), containersArray, functions.HookPostStop)
+		return errors.Join(shutdownErr, hookErr, RemoveContainers(containersArray))
 	}
-	flag.Parse()
 
-	containersArray, err := InitContainers(configDir, targetDevice, inputSrc, volumes, envOverrides, renderMode)
+	// The supervisor restarts the containers instead of Docker
+	if launch.supervise {
+		containersArray.DisableDockerRestarts()
+	}
+	// Written once started so the report exists while the containers run
+	runErr := RunContainers(ctx, containersArray)
+	if ctx.Err() != nil {
+		return shutdown("Interrupted")
+	}
+	// Containers that all started are left running when they aren't ready
+	// in time, so they can be looked into
+	readyFailed := false
+	if runErr == nil && launch.readyTimeout > 0 {
+		readyErr := WaitReadyContainers(ctx, containersArray, launch.readyTimeout)
+		if ctx.Err() != nil {
+			return shutdown("Interrupted")
+		}
+		if readyErr == nil {
+			report.Ready()
+			fmt.Println("Containers ready")
+		} else if launch.supervise {
+			// The supervisor restarts the containers that failed
+			fmt.Println(functions.MaskSecrets(readyErr.Error()))
+		} else {
+			runErr = readyErr
+			readyFailed = true
+		}
+	}
+	// Without a ready wait the containers count as ready once started
+	if runErr == nil && (launch.readyTimeout <= 0 || report.ReadyTime != nil) {
+		runErr = RunHooks(ctx, containersArray, functions.HookPostReady)
+		if ctx.Err() != nil {
+			return shutdown("Interrupted")
+		}
+	}
+	if runErr != nil && !readyFailed {
+		return errors.Join(runErr, shutdown("Launch failed"))
+	}
+	writeReport()
+	if runErr != nil {
+		if launch.runDir != "" {
+			fmt.Println("Containers left running, stop them with down", reportPath)
+		} else {
+			fmt.Println("Containers left running, stop them with docker stop")
+		}
+		return runErr
+	}
+	if !(launch.foreground || launch.supervise) {
+		return nil
+	}
+
+	var waitErr error
+	if launch.supervise {
+		eventsPath := ""
+		if launch.runDir != "" {
+			eventsPath = filepath.Join(launch.runDir, report.RunID, SupervisorEventsFile)
+		}
+		waitErr = SuperviseContainers(ctx, containersArray, eventsPath)
+	} else {
+		waitErr = WaitContainers(ctx, containersArray)
+	}
+	if ctx.Err() != nil {
+		return shutdown("Interrupted")
+	}
+	report.Finish()
+	writeReport()
+	hookErr := RunHooks(ctx, containersArray, functions.HookPostStop)
+	return errors.Join(waitErr, hookErr, RemoveContainers(containersArray))
+}
+
+func (flags *profileFlags) initContainers() (functions.Containers, error) {
+	containersArray, err := InitContainers(flags.configDir, flags.targetDevice, flags.inputSrc, flags.volumes, flags.envChanges(), flags.renderMode, flags.privileged, flags.export)
 	if err != nil {
-		fmt.Errorf("Failed to run contaienrs %v", err)
+		return containersArray, err
+	}
+	if err := flags.setCpusetPerReplica(&containersArray); err != nil {
+		return functions.Containers{}, err
+	}
+	return containersArray, nil
+}
 
+// Pin the replicas to the host CPUs when --cpuset-per-replica is set
+func (flags *profileFlags) setCpusetPerReplica(containersArray *functions.Containers) error {
+	if flags.cpusetPerReplica == "" {
+		return nil
 	}
+	topology, err := functions.ReadCPUTopology()
+	if err != nil {
+		return err
+	}
+	return containersArray.SetCpusetPerReplica(topology, flags.cpusetPerReplica)
+}
 
-	if runErr := RunContainers(containersArray); runErr != nil {
-		fmt.Errorf("Failed to run contaienrs %v", runErr)
+// The -e values followed by the --unset ones as !KEY overrides
+func (flags *profileFlags) envChanges() []string {
+	envChanges := slices.Clone(flags.envOverrides)
+	for _, unsetEnv := range flags.unsetEnvs {
+		name, key, found := strings.Cut(unsetEnv, ":")
+		if found {
+			envChanges = append(envChanges, name+":!"+key)
+		} else {
+			envChanges = append(envChanges, "!"+unsetEnv)
+		}
 	}
-	return
+	return envChanges
 }
 
-func InitContainers(configDir string, targetDevice string, inputSrc string, volumes []string, envOverrides []string, renderMode bool) (functions.Containers, error) {
+func main() {
+	// A failed launch exits non zero like the subcommands so CI can check it
+	if err := runMain(); err != nil {
+		fmt.Println(functions.MaskSecrets(err.Error()))
+		os.Exit(1)
+	}
+}
+
+// Run a subcommand when one is given, otherwise launch the profile
+func runMain() error {
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			return command(os.Args[2:])
+		}
+	}
+
+	var flags profileFlags
+	var launch launchFlags
+	flags.register(flag.CommandLine)
+	launch.register(flag.CommandLine)
+	flag.Parse()
+
+	containersArray, err := flags.initContainers()
+	if err != nil {
+		return fmt.Errorf("Failed to init containers %v", err)
+	}
+
+	report := functions.NewRunReport(flags.configDir)
+	if err := launch.run(&containersArray, &report); err != nil {
+		return fmt.Errorf("Failed to run containers %v", err)
+	}
+	return nil
+}
+
+func InitContainers(configDir string, targetDevices []string, inputSrcs []string, volumes []string, envOverrides []string, renderMode bool, privileged bool, export bool) (functions.Containers, error) {
 	// Load yaml config
 	containersArray, yamlErr := functions.GetYamlConfig(configDir)
 	if yamlErr != nil {
 		return functions.Containers{}, fmt.Errorf("Failed to load yaml config %v", yamlErr)
 	}
+	// Start dependencies before the containers that need them
+	if err := containersArray.SortByDependencies(); err != nil {
+		return functions.Containers{}, err
+	}
+	// Resource limits replace the matching HostConfig fields
+	if err := containersArray.SetResources(); err != nil {
+		return functions.Containers{}, err
+	}
+	if err := containersArray.SetRestartPolicies(); err != nil {
+		return functions.Containers{}, err
+	}
 	// Load ENV from .env file
 	if err := containersArray.GetEnv(configDir); err != nil {
 		return functions.Containers{}, fmt.Errorf("Failed to load ENV file %v", err)
 	}
-	containersArray.SetHostNetwork()
+	// Resolve secrets, the -e flags still win over secret envs
+	if err := containersArray.SetSecrets(configDir); err != nil {
+		return functions.Containers{}, fmt.Errorf("Failed to load secrets %v", err)
+	}
+	// Host networking unless the profile sets a bridge Network
+	if err := containersArray.SetNetwork(configDir); err != nil {
+		return functions.Containers{}, err
+	}
 
 	if renderMode == true {
 		for contIndex, _ := range containersArray.Containers {
 			containersArray.Containers[contIndex].Envs = append(containersArray.Containers[contIndex].Envs, "DISPLAY=$DISPLAY")
-			containersArray.Containers[contIndex].Volumes = append(containersArray.Containers[contIndex].Volumes, "/tmp/.X11-unix:/tmp/.X11-unix")
+			containersArray.Containers[contIndex].Volumes = append(containersArray.Containers[contIndex].Volumes, functions.BindVolume("/tmp/.X11-unix", "/tmp/.X11-unix"))
 		}
 	}
 
-	if targetDevice != "" {
-		envOverrides = append(envOverrides, "TARGET_DEVICE="+targetDevice)
-	}
-
 	// Set ENV overrides if any exist
 	if len(envOverrides) > 0 {
-		fmt.Println("Override Env")
+		fmt.Fprintln(os.Stderr, "Override Env")
 		if err := containersArray.OverrideEnv(envOverrides); err != nil {
 			return functions.Containers{}, err
 		}
@@ -115,33 +343,177 @@ func InitContainers(configDir string, targetDevice string, inputSrc string, volu
 		return functions.Containers{}, err
 	}
 
-	// Set the target device ENV
-	containersArray.TargetDevice = targetDevice
+	// Set the target device ENV, privileged mode can come from the config or the CLI
+	if err := containersArray.SetTargetDeviceOverrides(targetDevices); err != nil {
+		return functions.Containers{}, err
+	}
+	containersArray.Privileged = containersArray.Privileged || privileged
+	containersArray.Export = export
 	if err := containersArray.SetTargetDevice(); err != nil {
 		return functions.Containers{}, err
 	}
 
 	// Set the input source
-	containersArray.InputSrc = inputSrc
+	if err := containersArray.SetInputSrcOverrides(inputSrcs); err != nil {
+		return functions.Containers{}, err
+	}
 	if err := containersArray.SetInputSrc(); err != nil {
 		return functions.Containers{}, err
 	}
 
+	// Replicas are copies of the fully set up container
+	if err := containersArray.ExpandReplicas(); err != nil {
+		return functions.Containers{}, err
+	}
+	// Hooks about a container run for each of its replicas
+	if err := containersArray.SetHooks(configDir); err != nil {
+		return functions.Containers{}, err
+	}
+
 	return containersArray, nil
 }
 
-func RunContainers(containersArray functions.Containers) error {
+func RunContainers(ctx context.Context, containersArray *functions.Containers) error {
 	// Setup Docker CLI
-	ctx := context.Background()
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return err
 	}
 	defer cli.Close()
 
+	if err := containersArray.RunHooks(ctx, functions.DockerRuntime{Client: cli}, functions.HookPrePull, nil); err != nil {
+		return err
+	}
+	if err := containersArray.DockerPullImages(ctx, cli); err != nil {
+		return err
+	}
 	// Run each container found in config
 	if err := containersArray.DockerStartContainer(ctx, cli); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Wait for every started container to exit
+func WaitContainers(ctx context.Context, containersArray *functions.Containers) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	for contIndex, _ := range containersArray.Containers {
+		cont := &containersArray.Containers[contIndex]
+		if cont.ContainerID == "" {
+			continue
+		}
+		exitCode, err := cont.DockerWaitContainer(ctx, cli)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Container %v exited with code %d\n", cont.Name, exitCode)
+	}
+	return nil
+}
+
+// Wait until the containers are running, healthy and passing their probes
+func WaitReadyContainers(ctx context.Context, containersArray *functions.Containers, timeout time.Duration) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	return containersArray.WaitReady(ctx, functions.DockerRuntime{Client: cli}, timeout)
+}
+
+// Event log of the supervisor, one JSON event per line next to run.json
+const SupervisorEventsFile = "events.jsonl"
+
+// Restart the containers by their restart policy until none is left to run,
+// logging the restarts to eventsPath when it is set
+func SuperviseContainers(ctx context.Context, containersArray *functions.Containers, eventsPath string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	var events *os.File
+	if eventsPath != "" {
+		if err := os.MkdirAll(filepath.Dir(eventsPath), 0755); err != nil {
+			return fmt.Errorf("Failed to create run directory %v", err)
+		}
+		events, err = os.OpenFile(eventsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to open event log %v", err)
+		}
+		defer events.Close()
+	}
+	supervisor := functions.NewSupervisor(functions.DockerRuntime{Client: cli}, func(event functions.SupervisorEvent) {
+		fmt.Println(functions.MaskSecrets(event.String()))
+		if events == nil {
+			return
+		}
+		event.Reason = functions.MaskSecrets(event.Reason)
+		if line, err := json.Marshal(event); err == nil {
+			events.Write(append(line, '\n'))
+		}
+	})
+	return supervisor.Supervise(ctx, containersArray)
+}
+
+// Stop the containers in reverse dependency order, keeping their final logs
+// in logDir when it is set
+func ShutdownContainers(containersArray *functions.Containers, logDir string) error {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	return containersArray.Shutdown(ctx, functions.DockerRuntime{Client: cli}, logDir)
+}
+
+// Run the hooks of a stage that are not about a single started container
+func RunHooks(ctx context.Context, containersArray *functions.Containers, stage string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	return containersArray.RunHooks(ctx, functions.DockerRuntime{Client: cli}, stage, nil)
+}
+
+// Remove the stopped containers and the secret files written for them
+func RemoveContainers(containersArray *functions.Containers) error {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	if err := containersArray.DockerRemoveContainers(ctx, cli); err != nil {
+		return err
+	}
+	return containersArray.RemoveSecretFiles()
+}
+
+// Record the launched containers and their state in the run report
+func WriteRunReport(report *functions.RunReport, containersArray *functions.Containers, reportPath string) error {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	report.SetContainers(containersArray)
+	if err := report.InspectContainers(ctx, cli); err != nil {
+		return err
+	}
+	return report.Write(reportPath)
+}
@@ -36,9 +36,9 @@ func CreateTestContainers(inputSrc string, targetDevice string) functions.Contai
 		Containers: []functions.Container{{
 			Name:                     "Client",
 			DockerImage:              "test:dev",
-			Entrypoint:               "/script/entrypoint.sh",
+			Entrypoint:               functions.CommandArgs{"/script/entrypoint.sh"},
 			EnvironmentVariableFiles: "profile.env",
-			Volumes:                  []string{"./test-profile:/test-profile"},
+			Volumes:                  []functions.VolumeSpec{functions.BindVolume("./test-profile", "/test-profile")},
 			Envs:                     []string{"TEST_ENV=123", "TEST_ENV2=abc", "INPUTSRC=/dev/video0"},
 		}},
 	}
@@ -102,7 +102,7 @@ func TestMain(t *testing.T) {
 	}
 	defer cli.Close()
 
-	os.Args = append(os.Args, []string{"--configdir", "./test-profile/main-test-profile", "--inputsrc", "/dev/video0", "--target_device", "CPU", "-e", "test=123", "-v", "./test-profile:/test"}...)
+	os.Args = append(os.Args, []string{"--configdir", "./test-profile/main-test-profile", "--inputsrc", "/dev/video0", "--target_device", "CPU", "-e", "test=123", "-v", "./test-profile:/test", "--run_dir", t.TempDir()}...)
 	tests := []struct {
 		name               string
 		profile            string
@@ -114,7 +114,7 @@ func TestMain(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			require.NoError(t, WriteYamlConfig(tt.profile))
-			main()
+			require.Error(t, runMain())
 
 			containerList, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
 			if err != nil {
@@ -154,16 +154,16 @@ func TestInitContainers(t *testing.T) {
 		expectedContainers     functions.Containers
 		expectedContainerCount int
 	}{
-		{"valid with only flags", validYaml, testConfigDir, "CPU", "/dev/video0", []string{}, []string{}, true, false, CreateTestContainers("CPU", "/dev/video0"), 1},
-		{"valid with env overrides", validYaml, testConfigDir, "CPU", "/dev/video0", []string{}, []string{"TEST_ENV=def"}, false, false, CreateTestContainers("CPU", "/dev/video0"), 1},
-		{"valid with volume input", validYaml, testConfigDir, "CPU", "/dev/video0", []string{"./test-profile:/test-profile"}, []string{}, false, false, CreateTestContainers("CPU", "/dev/video0"), 1},
+		{"valid with only flags", validYaml, testConfigDir, "CPU", "rtsp://127.0.0.1:8554/camera_0", []string{}, []string{}, true, false, CreateTestContainers("CPU", "/dev/video0"), 1},
+		{"valid with env overrides", validYaml, testConfigDir, "CPU", "rtsp://127.0.0.1:8554/camera_0", []string{}, []string{"TEST_ENV=def"}, false, false, CreateTestContainers("CPU", "/dev/video0"), 1},
+		{"valid with volume input", validYaml, testConfigDir, "CPU", "rtsp://127.0.0.1:8554/camera_0", []string{"./test-profile:/test-profile"}, []string{}, false, false, CreateTestContainers("CPU", "/dev/video0"), 1},
 		{"invalid no configdir set", "", "", "", "", []string{}, []string{}, false, true, functions.Containers{}, 0},
 		{"invalid config format", invalidConfig, testConfigDir, "", "", []string{}, []string{}, false, true, functions.Containers{}, 0},
 		{"invalid missing env", invalidEnvFile, testConfigDir, "", "", []string{}, []string{}, false, true, functions.Containers{}, 0},
 		{"invalid inputSrc not set", validYaml, testConfigDir, "", "", []string{}, []string{}, false, true, functions.Containers{}, 0},
 		{"invalid targetDevice not set", validYaml, testConfigDir, "Fake", "", []string{}, []string{}, false, true, functions.Containers{}, 0},
-		{"invalid with env overrides", validYaml, testConfigDir, "CPU", "/dev/video0", []string{}, []string{"TEST_ENV"}, false, true, CreateTestContainers("CPU", "/dev/video0"), 0},
-		{"invalid with volume input", validYaml, testConfigDir, "CPU", "/dev/video0", []string{testConfigDir}, []string{}, false, true, CreateTestContainers("CPU", "/dev/video0"), 0},
+		{"invalid with env overrides", validYaml, testConfigDir, "CPU", "rtsp://127.0.0.1:8554/camera_0", []string{}, []string{"TEST_ENV"}, false, true, CreateTestContainers("CPU", "/dev/video0"), 0},
+		{"invalid with volume input", validYaml, testConfigDir, "CPU", "rtsp://127.0.0.1:8554/camera_0", []string{testConfigDir}, []string{}, false, true, CreateTestContainers("CPU", "/dev/video0"), 0},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -172,7 +172,7 @@ func TestInitContainers(t *testing.T) {
 			tmpContainers.SetHostNetwork()
 
 			hasError := false
-			containersArray, err := InitContainers(tt.configDir, tt.targetDevice, tt.inputSrc, tt.volumes, tt.envOverrides, tt.renderMode)
+			containersArray, err := InitContainers(tt.configDir, []string{tt.targetDevice}, []string{tt.inputSrc}, tt.volumes, tt.envOverrides, tt.renderMode, false, false)
 			if err != nil {
 				hasError = true
 
@@ -205,7 +205,7 @@ func TestRunContainers(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			hasError := false
-			err := RunContainers(tt.expectedContainers)
+			err := RunContainers(context.Background(), &tt.expectedContainers)
 			if err != nil {
 				hasError = true
 
@@ -224,3 +224,12 @@ func TestRunContainers(t *testing.T) {
 		})
 	}
 }
+
+// TestEnvChanges: test --unset values following the -e ones
+func TestEnvChanges(t *testing.T) {
+	flags := profileFlags{
+		envOverrides: arrayFlags{"TEST_ENV=def", "Client:BATCH=4"},
+		unsetEnvs:    arrayFlags{"TEST_ENV2", "Client:MODEL"},
+	}
+	require.Equal(t, []string{"TEST_ENV=def", "Client:BATCH=4", "!TEST_ENV2", "Client:!MODEL"}, flags.envChanges())
+}
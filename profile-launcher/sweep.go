@@ -0,0 +1,263 @@
+// ----------------------------------------------------------------------------------
+// Copyright 2024 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+//
+// ----------------------------------------------------------------------------------
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/intel-retail/core-services/profile-launcher/functions"
+)
+
+// Run a profile once per combination of a matrix file: sweep <matrix.yaml> [launch flags]
+func sweepCommand(args []string) error {
+	usage := fmt.Errorf("usage: profile-launcher sweep <matrix.yaml> [--output dir] [--timeout 10m] [launch flags]")
+
+	var flags profileFlags
+	var outputDir, timeout string
+	flagSet := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	flags.register(flagSet)
+	flagSet.StringVar(&outputDir, "output", "", "Directory to write the combination results to, overrides the matrix OutputDir")
+	flagSet.StringVar(&timeout, "timeout", "", "Longest a combination may run, overrides the matrix Timeout")
+	positional, err := parseFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usage
+	}
+
+	matrix, err := functions.LoadSweepMatrix(positional[0])
+	if err != nil {
+		return err
+	}
+	// The matrix names the profile, --configdir only wins when given
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == "configdir" {
+			matrix.ConfigDir = flags.configDir
+		}
+	})
+	if outputDir != "" {
+		matrix.OutputDir = outputDir
+	}
+	if timeout != "" {
+		matrix.Timeout = timeout
+	}
+	combinationTimeout, err := matrix.GetTimeout()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(matrix.OutputDir, 0755); err != nil {
+		return fmt.Errorf("Failed to create sweep output directory %v", err)
+	}
+
+	// The first SIGINT or SIGTERM stops the running combination and ends the
+	// sweep, a second one ends the launcher right away
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	context.AfterFunc(ctx, stopSignals)
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	combinations := matrix.Combinations()
+	var results []functions.SweepResult
+	failed := 0
+	for index, combination := range combinations {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("Sweep combination %d/%d: %v\n", index+1, len(combinations), combination.Name)
+		result := runSweepCombination(ctx, cli, matrix, combination, flags, combinationTimeout)
+		if result.Status != functions.SweepPassed {
+			failed++
+			fmt.Printf("Sweep combination %v %v %v\n", combination.Name, result.Status, result.Error)
+		}
+		results = append(results, result)
+	}
+
+	summaryFile, err := os.Create(filepath.Join(matrix.OutputDir, "summary.txt"))
+	if err != nil {
+		return fmt.Errorf("Failed to write sweep summary %v", err)
+	}
+	defer summaryFile.Close()
+	if err := functions.WriteSweepSummary(summaryFile, results); err != nil {
+		return err
+	}
+	if err := functions.WriteSweepSummary(os.Stdout, results); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("Sweep interrupted after %d of %d combinations", len(results), len(combinations))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sweep combinations did not pass", failed, len(combinations))
+	}
+	return nil
+}
+
+// Launch a single combination, wait for it to end and keep its logs, run
+// report and result in its own directory
+func runSweepCombination(ctx context.Context, cli *client.Client, matrix functions.SweepMatrix, combination functions.SweepCombination, flags profileFlags, timeout time.Duration) (result functions.SweepResult) {
+	result = functions.SweepResult{Combination: combination, StartTime: time.Now()}
+	combinationDir := filepath.Join(matrix.OutputDir, combination.Name)
+	defer func() {
+		result.Duration = time.Since(result.StartTime).Round(time.Second).String()
+		writeSweepJSON(filepath.Join(combinationDir, "result.json"), result)
+	}()
+	fail := func(status string, err error) functions.SweepResult {
+		result.Status = status
+		result.Error = functions.MaskSecrets(err.Error())
+		return result
+	}
+
+	if err := os.MkdirAll(combinationDir, 0755); err != nil {
+		return fail(functions.SweepError, fmt.Errorf("Failed to create combination directory %v", err))
+	}
+	// Matrix values come after the launch flags so they win over them
+	volumes := slices.Clone(flags.volumes)
+	if matrix.ResultsDir != "" {
+		resultsDir, err := filepath.Abs(filepath.Join(combinationDir, "results"))
+		if err == nil {
+			err = os.MkdirAll(resultsDir, 0777)
+		}
+		if err != nil {
+			return fail(functions.SweepError, fmt.Errorf("Failed to create results directory %v", err))
+		}
+		volumes = append(volumes, resultsDir+":"+matrix.ResultsDir)
+	}
+	containersArray, err := InitContainers(matrix.ConfigDir,
+		append(slices.Clone(flags.targetDevice), combination.TargetDevice...),
+		append(slices.Clone(flags.inputSrc), combination.InputSrc...),
+		volumes,
+		append(flags.envChanges(), combination.Envs...),
+		flags.renderMode, flags.privileged, false)
+	if err == nil {
+		err = flags.setCpusetPerReplica(&containersArray)
+	}
+	if err != nil {
+		return fail(functions.SweepError, fmt.Errorf("Failed to init containers %v", err))
+	}
+	waitFor := matrix.WaitFor
+	if len(waitFor) == 0 {
+		for _, cont := range containersArray.Containers {
+			waitFor = append(waitFor, cont.Name)
+		}
+	}
+	for _, name := range waitFor {
+		if _, err := containersArray.GetContainer(name); err != nil {
+			return fail(functions.SweepError, fmt.Errorf("WaitFor: %v", err))
+		}
+	}
+
+	// Always clean up so the next combination can reuse the container names,
+	// once the resolved containers and their state are in run.json. Cleaning
+	// up goes on when the sweep is interrupted.
+	cleanupCtx := context.WithoutCancel(ctx)
+	report := functions.NewRunReport(matrix.ConfigDir)
+	containersArray.HookContext.RunID = report.RunID
+	containersArray.HookContext.LogDir = combinationDir
+	defer containersArray.RemoveSecretFiles()
+	defer containersArray.DockerRemoveContainers(cleanupCtx, cli)
+	defer func() {
+		report.Finish()
+		if err := WriteRunReport(&report, &containersArray, filepath.Join(combinationDir, functions.RunReportFile)); err != nil {
+			fmt.Printf("Failed to write run report %v\n", err)
+		}
+	}()
+	// Containers left running such as servers are stopped once the others
+	// are done, the same way a launch stops them
+	defer func() {
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted, stopping the containers")
+			fail(functions.SweepError, fmt.Errorf("Interrupted"))
+		}
+		runtime := functions.DockerRuntime{Client: cli}
+		if err := containersArray.Shutdown(cleanupCtx, runtime, combinationDir); err != nil && result.Status == functions.SweepPassed {
+			fail(functions.SweepError, err)
+		}
+		if err := containersArray.RunHooks(cleanupCtx, runtime, functions.HookPostStop, nil); err != nil && result.Status == functions.SweepPassed {
+			fail(functions.SweepError, err)
+		}
+		for contIndex, _ := range containersArray.Containers {
+			cont := &containersArray.Containers[contIndex]
+			if cont.ContainerID == "" {
+				continue
+			}
+			contResult := functions.SweepContainerResult{Name: cont.Name, LogFile: cont.Name + ".log"}
+			contResult.ExitCode, _ = cont.DockerWaitContainer(cleanupCtx, cli)
+			if _, err := os.Stat(filepath.Join(combinationDir, contResult.LogFile)); err != nil {
+				contResult.LogFile = ""
+			}
+			result.Containers = append(result.Containers, contResult)
+		}
+	}()
+	if err := RunContainers(ctx, &containersArray); err != nil {
+		return fail(functions.SweepError, fmt.Errorf("Failed to run containers %v", err))
+	}
+	if err := containersArray.RunHooks(ctx, functions.DockerRuntime{Client: cli}, functions.HookPostReady, nil); err != nil {
+		return fail(functions.SweepError, err)
+	}
+
+	waitCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result.Status = functions.SweepPassed
+	for _, name := range waitFor {
+		cont, _ := containersArray.GetContainer(name)
+		exitCode, err := cont.DockerWaitContainer(waitCtx, cli)
+		if err != nil && ctx.Err() != nil {
+			break
+		} else if err != nil && waitCtx.Err() != nil {
+			fail(functions.SweepTimeout, fmt.Errorf("Container %v still running after %v", name, timeout))
+			break
+		} else if err != nil {
+			fail(functions.SweepError, err)
+			break
+		} else if exitCode != 0 && result.Status == functions.SweepPassed {
+			fail(functions.SweepFailed, fmt.Errorf("Container %v exited with code %d", name, exitCode))
+		}
+	}
+	return result
+}
+
+// Write a sweep file as indented JSON, failures are reported but don't stop the sweep
+func writeSweepJSON(path string, value interface{}) {
+	contents, err := json.MarshalIndent(value, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(contents, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Printf("Failed to write %v %v\n", path, err)
+	}
+}